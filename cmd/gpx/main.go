@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -9,6 +10,8 @@ import (
 	"github.com/ZeraiGR/gpx/internal/app"
 	"github.com/ZeraiGR/gpx/internal/config"
 	"github.com/ZeraiGR/gpx/internal/envx"
+	"github.com/ZeraiGR/gpx/internal/goenv"
+	"github.com/ZeraiGR/gpx/internal/output"
 	"github.com/ZeraiGR/gpx/internal/shell"
 )
 
@@ -33,6 +36,8 @@ func main() {
 		statusCmd(os.Args[2:])
 	case "use":
 		useCmd(os.Args[2:])
+	case "off":
+		offCmd(os.Args[2:])
 	case "set":
 		setCmd(os.Args[2:])
 	case "unset":
@@ -41,8 +46,30 @@ func main() {
 		diffCmd(os.Args[2:])
 	case "apply":
 		applyCmd(os.Args[2:])
+	case "unapply":
+		unapplyCmd(os.Args[2:])
+	case "backups":
+		backupsCmd(os.Args[2:])
+	case "restore":
+		restoreCmd(os.Args[2:])
+	case "exec":
+		execCmd(os.Args[2:])
+	case "shell":
+		shellCmd(os.Args[2:])
+	case "shell-init":
+		shellInitCmd(os.Args[2:])
+	case "hook":
+		hookCmd(os.Args[2:])
+	case "allow":
+		allowCmd(os.Args[2:], true)
+	case "deny":
+		allowCmd(os.Args[2:], false)
 	case "profile":
 		profileCmd(os.Args[2:])
+	case "config":
+		configCmd(os.Args[2:])
+	case "paths":
+		pathsCmd(os.Args[2:])
 	case "version":
 		versionCmd()
 	default:
@@ -56,34 +83,128 @@ func usage() {
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  gpx init   [--force] [--config PATH]")
-	fmt.Println("  gpx list   [--config PATH]")
-	fmt.Println("  gpx status [--config PATH]")
-	fmt.Println("  gpx use <profile> [--config PATH]")
-	fmt.Println("  gpx set KEY=VALUE [KEY=VALUE ...] [--config PATH]")
-	fmt.Println("  gpx unset KEY [KEY ...]")
-	fmt.Println("  gpx diff <profile> [--config PATH]")
-	fmt.Println("  gpx apply [--rc PATH] [--shell zsh|bash] [--dry-run] [--backup] <profile> [--config PATH]")
+	fmt.Println("  gpx list   [--format FMT] [--config PATH]")
+	fmt.Println("  gpx status [--format FMT] [--rc PATH] [--shell NAME] [--config PATH]")
+	fmt.Println("  gpx use [--shell NAME] [--save] <profile> [--config PATH]")
+	fmt.Println("  gpx off [--shell NAME] [--config PATH]")
+	fmt.Println("  gpx set [--shell NAME] KEY=VALUE [KEY=VALUE ...] [--config PATH]")
+	fmt.Println("  gpx unset [--shell NAME] KEY [KEY ...]")
+	fmt.Println("  gpx diff [--format FMT] <profile> [--config PATH]")
+	fmt.Println("  gpx exec [--config PATH] <profile> -- <command> [args ...]")
+	fmt.Println("  gpx shell [--shell PATH] [--force] [--config PATH] <profile>")
+	fmt.Println("  gpx apply [--target rc|goenv] [--rc PATH] [--shell NAME] [--dry-run] [--backup] [--repair] [--block NAME] [--replace-symlink] <profile> [--config PATH]")
+	fmt.Println("  gpx unapply [--rc PATH] [--shell NAME] [--dry-run] [--backup] [--block NAME] [--replace-symlink] [--config PATH]")
+	fmt.Println("  gpx backups [--format FMT] [--rc PATH] [--shell NAME]")
+	fmt.Println("  gpx restore [--rc PATH] [--shell NAME] [--dry-run] [--backup] [--replace-symlink] [BACKUP] [--config PATH]")
+	fmt.Println("  gpx shell-init [--hook] [--install [--rc PATH] [--dry-run] [--backup] [--replace-symlink] [--config PATH]] [bash|zsh|fish]")
+	fmt.Println()
+	fmt.Println("Directory-bound profiles (.gpx):")
+	fmt.Println("  gpx allow [PATH] [--config PATH]")
+	fmt.Println("  gpx deny [PATH] [--config PATH]")
+	fmt.Println("  gpx hook [--shell NAME] [--config PATH]")
 	fmt.Println()
 	fmt.Println("Config editing:")
-	fmt.Println("  gpx profile add <name> [--config PATH]")
-	fmt.Println("  gpx profile rm <name> [--config PATH]")
-	fmt.Println("  gpx profile rename <old> <new> [--config PATH]")
-	fmt.Println("  gpx profile show <name> [--config PATH]")
-	fmt.Println("  gpx profile set <name> KEY=VALUE [KEY=VALUE ...] [--config PATH]")
-	fmt.Println("  gpx profile unset <name> KEY [KEY ...] [--config PATH]")
+	fmt.Println("  gpx profile add [--layer LAYER] [--force] <name> [--config PATH]")
+	fmt.Println("  gpx profile rm [--layer LAYER] [--force] <name> [--config PATH]")
+	fmt.Println("  gpx profile rename [--layer LAYER] [--force] <old> <new> [--config PATH]")
+	fmt.Println("  gpx profile show [--resolved] [--origin] [--format FMT] <name> [--config PATH]")
+	fmt.Println("  gpx profile extends [--layer LAYER] [--force] <name> [parent ...] [--config PATH]")
+	fmt.Println("  gpx profile set [--layer LAYER] [--force] <name> KEY=VALUE [KEY=VALUE ...] [--config PATH]")
+	fmt.Println("  gpx profile unset [--layer LAYER] [--force] <name> KEY [KEY ...] [--config PATH]")
+	fmt.Println("  gpx config convert --to json|yaml|toml [--dry-run] [--keep] [--config PATH]")
+	fmt.Println("  gpx config migrate [--dry-run] [--config PATH]")
 	fmt.Println()
+	fmt.Println("  gpx paths [--format FMT] [--config PATH]")
 	fmt.Println("  gpx version")
 	fmt.Println()
+	fmt.Println("Output format (FMT): text (default), json or yaml; GPX_FORMAT sets the default.")
+	fmt.Println("Commands taking --config also take --state PATH; by default each config has its own state file.")
+	fmt.Println("Config layers (LAYER): system (/etc/gpx), user (default) and project (.gpx/config.*, needs gpx allow).")
+	fmt.Println()
 	fmt.Println("Tips:")
 	fmt.Println(`  eval "$(gpx use public)"`)
+	fmt.Println(`  eval "$(gpx shell-init zsh)"   # then just: gpx use public`)
 }
 
 func resolveConfigPath(fs *flag.FlagSet) *string {
-	return fs.String("config", "", "path to config file (default: ~/.config/gpx/config.json)")
+	return fs.String("config", "", "path to config file (default: $GPX_CONFIG or $XDG_CONFIG_HOME/gpx/config.{json,yaml,toml})")
+}
+
+func formatFlag(fs *flag.FlagSet) *string {
+	def := os.Getenv("GPX_FORMAT")
+	if def == "" {
+		def = output.FormatText
+	}
+	return fs.String("format", def, "output format: text, json or yaml (default from GPX_FORMAT)")
+}
+
+// printDoc prints doc in a machine-readable format.
+// It returns false for text, which callers render themselves.
+func printDoc(format string, doc any) bool {
+	if err := output.Validate(format); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+	if format == output.FormatText {
+		return false
+	}
+	if err := output.Encode(os.Stdout, format, doc); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	return true
 }
 
-func makeApp(cfgPath string) app.App {
-	return app.App{ConfigPath: cfgPath}
+func stateFlag(fs *flag.FlagSet) *string {
+	return fs.String("state", "", "path to state file (default: $GPX_STATE, or derived from the config path)")
+}
+
+func makeApp(cfgPath, statePath string) app.App {
+	return app.App{ConfigPath: cfgPath, StatePath: statePath}
+}
+
+func replaceSymlinkFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("replace-symlink", false, "if the rc file is a symlink into a read-only location, replace the link with a regular file")
+}
+
+// noteSymlink says which file was written when the rc path is a symlink.
+func noteSymlink(rcPath, target string) {
+	if target != "" && target != rcPath {
+		fmt.Printf("Note: %s is a symlink; wrote %s\n", rcPath, target)
+	}
+}
+
+func blockFlag(fs *flag.FlagSet) *string {
+	return fs.String("block", "", "name of the GPX block in the rc file (default: the unnamed block)")
+}
+
+func blockMarkersOrExit(name string) shell.Markers {
+	if err := shell.ValidateBlockName(name); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+	return shell.ProfileMarkers.Named(name)
+}
+
+func dialectFlag(fs *flag.FlagSet) *string {
+	return fs.String("shell", "", "output syntax: sh, bash, zsh, fish, pwsh or nu (default: detected)")
+}
+
+// shellOrDetect returns name, or the detected shell when name is empty.
+func shellOrDetect(name string) string {
+	if name != "" {
+		return name
+	}
+	return shell.Detect().Shell
+}
+
+func dialectOrExit(name string) envx.Dialect {
+	d, err := envx.DialectFor(shellOrDetect(name))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+	return d
 }
 
 func defaultConfigPathOrExit() string {
@@ -96,7 +217,15 @@ func defaultConfigPathOrExit() string {
 }
 
 // enforce flags-first contract: <cmd> [flags] <args>
+// args are the positional arguments left after fs.Parse, so flag values are not
+// mistaken for <args>. Everything after "--" is not checked.
 func ensureFlagsBeforeArgs(args []string, cmdName string) {
+	for i, a := range args {
+		if a == "--" {
+			args = args[:i]
+			break
+		}
+	}
 	pos := -1
 	for i, a := range args {
 		if !strings.HasPrefix(a, "-") {
@@ -137,6 +266,8 @@ func initCmd(args []string) {
 func listCmd(args []string) {
 	fs := flag.NewFlagSet("list", flag.ExitOnError)
 	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	format := formatFlag(fs)
 	_ = fs.Parse(args)
 
 	path := *cfgPath
@@ -144,18 +275,25 @@ func listCmd(args []string) {
 		path = defaultConfigPathOrExit()
 	}
 
-	a := makeApp(path)
+	a := makeApp(path, *statePath)
 	items, err := a.ListProfiles()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
+	if printDoc(*format, app.ListDoc(items)) {
+		return
+	}
 	fmt.Print(app.FormatProfiles(items))
 }
 
 func statusCmd(args []string) {
 	fs := flag.NewFlagSet("status", flag.ExitOnError)
 	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	rc := fs.String("rc", "", "rc file to inspect (default: detected shell's rc file)")
+	shName := fs.String("shell", "", "shell of the rc file (default: detected)")
+	format := formatFlag(fs)
 	_ = fs.Parse(args)
 
 	path := *cfgPath
@@ -163,20 +301,58 @@ func statusCmd(args []string) {
 		path = defaultConfigPathOrExit()
 	}
 
-	a := makeApp(path)
-	rows, err := a.Status()
+	det := shell.Detect()
+	if *shName == "" && *rc != "" {
+		*shName = shell.ShellForRC(*rc)
+	}
+	if *shName == "" {
+		*shName = det.Shell
+	}
+	d := dialectOrExit(*shName)
+
+	rcPath := *rc
+	if rcPath == "" {
+		// best-effort: status still works without an rc file
+		rcPath, _ = shell.DefaultRC(*shName)
+	}
+	goEnvPath, _ := goenv.Path() // GOENV=off: nothing to show
+
+	a := makeApp(path, *statePath)
+	rows, err := a.Status(app.StatusOptions{RCPath: rcPath, Dialect: d, GoEnvPath: goEnvPath})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
+	// An unreadable rc file shows up in the rc rows; status goes on.
+	blocks, blocksErr := a.RCBlocks(rcPath, d)
+	if printDoc(*format, app.StatusDoc(rows, blocks, det, rcPath, goEnvPath)) {
+		return
+	}
+	fmt.Printf("Shell: %s\n", det)
+	if rcPath != "" {
+		fmt.Printf("RC file: %s\n", rcPath)
+		if blocksErr != nil {
+			fmt.Printf("GPX blocks: (error: %v)\n", blocksErr)
+		} else {
+			fmt.Print(app.FormatRCBlocks(blocks))
+		}
+	}
+	if goEnvPath != "" {
+		fmt.Printf("Go env file: %s\n", goEnvPath)
+	}
+	fmt.Println()
 	fmt.Print(app.FormatStatus(rows))
 }
 
 func useCmd(args []string) {
-	ensureFlagsBeforeArgs(args, "use")
 	fs := flag.NewFlagSet("use", flag.ExitOnError)
 	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	save := fs.Bool("save", false, "save original values so `gpx off` can restore them")
+	shName := dialectFlag(fs)
 	_ = fs.Parse(args)
+	ensureFlagsBeforeArgs(fs.Args(), "use")
+	d := dialectOrExit(*shName)
 
 	rest := fs.Args()
 	if len(rest) < 1 {
@@ -190,8 +366,32 @@ func useCmd(args []string) {
 		path = defaultConfigPathOrExit()
 	}
 
-	a := makeApp(path)
-	lines, err := a.UseProfile(name)
+	a := makeApp(path, *statePath)
+	lines, err := a.UseProfile(name, app.UseOptions{Dialect: d, Save: *save})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	for _, ln := range lines {
+		fmt.Println(ln)
+	}
+}
+
+func offCmd(args []string) {
+	fs := flag.NewFlagSet("off", flag.ExitOnError)
+	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	shName := dialectFlag(fs)
+	_ = fs.Parse(args)
+	d := dialectOrExit(*shName)
+
+	path := *cfgPath
+	if path == "" {
+		path = defaultConfigPathOrExit()
+	}
+
+	a := makeApp(path, *statePath)
+	lines, err := a.Off(d)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
@@ -202,10 +402,13 @@ func useCmd(args []string) {
 }
 
 func setCmd(args []string) {
-	ensureFlagsBeforeArgs(args, "set")
 	fs := flag.NewFlagSet("set", flag.ExitOnError)
 	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	shName := dialectFlag(fs)
 	_ = fs.Parse(args)
+	ensureFlagsBeforeArgs(fs.Args(), "set")
+	d := dialectOrExit(*shName)
 
 	tokens := fs.Args()
 	if len(tokens) == 0 {
@@ -218,8 +421,8 @@ func setCmd(args []string) {
 		path = defaultConfigPathOrExit()
 	}
 
-	a := makeApp(path)
-	lines, err := a.SetVars(tokens)
+	a := makeApp(path, *statePath)
+	lines, err := a.SetVars(tokens, d)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
@@ -230,11 +433,18 @@ func setCmd(args []string) {
 }
 
 func unsetCmd(args []string) {
-	if len(args) == 0 {
+	fs := flag.NewFlagSet("unset", flag.ExitOnError)
+	shName := dialectFlag(fs)
+	_ = fs.Parse(args)
+	ensureFlagsBeforeArgs(fs.Args(), "unset")
+	d := dialectOrExit(*shName)
+
+	keys := fs.Args()
+	if len(keys) == 0 {
 		fmt.Fprintln(os.Stderr, "error: expected at least one KEY")
 		os.Exit(2)
 	}
-	lines, err := envx.UnsetLines(args)
+	lines, err := envx.UnsetLinesFor(d, keys)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
@@ -245,10 +455,12 @@ func unsetCmd(args []string) {
 }
 
 func diffCmd(args []string) {
-	ensureFlagsBeforeArgs(args, "diff")
 	fs := flag.NewFlagSet("diff", flag.ExitOnError)
 	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	format := formatFlag(fs)
 	_ = fs.Parse(args)
+	ensureFlagsBeforeArgs(fs.Args(), "diff")
 
 	rest := fs.Args()
 	if len(rest) < 1 {
@@ -262,24 +474,32 @@ func diffCmd(args []string) {
 		path = defaultConfigPathOrExit()
 	}
 
-	a := makeApp(path)
+	a := makeApp(path, *statePath)
 	rows, err := a.DiffProfile(profile)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
+	if printDoc(*format, app.DiffDoc(profile, rows)) {
+		return
+	}
 	fmt.Print(app.FormatDiff(rows))
 }
 
 func applyCmd(args []string) {
-	ensureFlagsBeforeArgs(args, "apply")
 	fs := flag.NewFlagSet("apply", flag.ExitOnError)
 	cfgPath := resolveConfigPath(fs)
-	shName := fs.String("shell", "zsh", "shell type: zsh or bash (used only when --rc is not provided)")
+	statePath := stateFlag(fs)
+	shName := fs.String("shell", "", "shell type: sh, zsh, bash, fish, pwsh or nu (selects default rc file and output syntax; default: detected)")
 	rc := fs.String("rc", "", "rc file path (overrides --shell default)")
 	dryRun := fs.Bool("dry-run", false, "show what would be written, but do not modify any file")
 	backup := fs.Bool("backup", false, "create a backup of rc file before modifying it")
+	repair := fs.Bool("repair", false, "collapse duplicate or malformed GPX blocks in the rc file into one")
+	blockName := blockFlag(fs)
+	replaceSymlink := replaceSymlinkFlag(fs)
+	target := fs.String("target", "rc", "where to apply: rc (shell rc file) or goenv (go env file, see `go env GOENV`)")
 	_ = fs.Parse(args)
+	ensureFlagsBeforeArgs(fs.Args(), "apply")
 
 	rest := fs.Args()
 	if len(rest) < 1 {
@@ -293,6 +513,24 @@ func applyCmd(args []string) {
 		path = defaultConfigPathOrExit()
 	}
 
+	switch *target {
+	case "rc":
+	case "goenv":
+		a := makeApp(path, *statePath)
+		applyGoEnv(a, profile, shell.ApplyOptions{DryRun: *dryRun, Backup: *backup, Retention: a.BackupRetention()})
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown --target %q (expected rc or goenv)\n", *target)
+		os.Exit(2)
+	}
+
+	// Without --shell: the --rc file extension picks the shell, then detection.
+	if *shName == "" && *rc != "" {
+		*shName = shell.ShellForRC(*rc)
+	}
+	*shName = shellOrDetect(*shName)
+	d := dialectOrExit(*shName)
+
 	rcPath := *rc
 	if rcPath == "" {
 		p, err := shell.DefaultRC(*shName)
@@ -303,17 +541,25 @@ func applyCmd(args []string) {
 		rcPath = p
 	}
 
-	a := makeApp(path)
-	report, err := a.ApplyProfileToRC(profile, rcPath, shell.ApplyOptions{
-		DryRun: *dryRun,
-		Backup: *backup,
+	a := makeApp(path, *statePath)
+	report, err := a.ApplyProfileToRC(profile, rcPath, d, shell.ApplyOptions{
+		DryRun:         *dryRun,
+		Backup:         *backup,
+		Repair:         *repair,
+		Markers:        blockMarkersOrExit(*blockName),
+		ReplaceSymlink: *replaceSymlink,
+		Retention:      a.BackupRetention(),
 	})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
+		var berr *shell.BlockError
+		if errors.As(err, &berr) {
+			fmt.Fprintln(os.Stderr, "hint: check the rc file, then rerun with --repair to collapse the blocks into one")
+		}
 		os.Exit(1)
 	}
 
-	if !*backup {
+	if !*backup && !*dryRun {
 		fmt.Println("Note: no backup was created (use --backup to enable)")
 	}
 
@@ -325,33 +571,475 @@ func applyCmd(args []string) {
 	}
 
 	fmt.Printf("Applied profile %q to %s\n", profile, report.RCPath)
+	noteSymlink(report.RCPath, report.Target)
+	if report.BackupPath != "" {
+		fmt.Printf("Backup: %s\n", report.BackupPath)
+	}
+	fmt.Printf("Next: source %s (or restart shell)\n", report.RCPath)
+}
+
+func unapplyCmd(args []string) {
+	fs := flag.NewFlagSet("unapply", flag.ExitOnError)
+	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	shName := fs.String("shell", "", "shell type: sh, zsh, bash, fish, pwsh or nu (selects default rc file; default: detected)")
+	rc := fs.String("rc", "", "rc file path (overrides --shell default)")
+	dryRun := fs.Bool("dry-run", false, "show the result, but do not modify any file")
+	backup := fs.Bool("backup", false, "create a backup of rc file before modifying it")
+	blockName := blockFlag(fs)
+	replaceSymlink := replaceSymlinkFlag(fs)
+	_ = fs.Parse(args)
+	ensureFlagsBeforeArgs(fs.Args(), "unapply")
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "error: unapply takes no arguments")
+		os.Exit(2)
+	}
+
+	path := *cfgPath
+	if path == "" {
+		path = defaultConfigPathOrExit()
+	}
+
+	if *shName == "" && *rc != "" {
+		*shName = shell.ShellForRC(*rc)
+	}
+	*shName = shellOrDetect(*shName)
+	d := dialectOrExit(*shName)
+
+	rcPath := *rc
+	if rcPath == "" {
+		p, err := shell.DefaultRC(*shName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		rcPath = p
+	}
+
+	a := makeApp(path, *statePath)
+	report, err := a.UnapplyFromRC(rcPath, d, shell.ApplyOptions{
+		DryRun:         *dryRun,
+		Backup:         *backup,
+		Markers:        blockMarkersOrExit(*blockName),
+		ReplaceSymlink: *replaceSymlink,
+		Retention:      a.BackupRetention(),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		var berr *shell.BlockError
+		if errors.As(err, &berr) {
+			fmt.Fprintln(os.Stderr, "hint: fix the rc file by hand, or collapse the blocks with gpx apply --repair first")
+		}
+		os.Exit(1)
+	}
+	if !report.Found {
+		fmt.Printf("No GPX block in %s; nothing to do\n", report.RCPath)
+		return
+	}
+
+	if !*backup && !*dryRun {
+		fmt.Println("Note: no backup was created (use --backup to enable)")
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry-run: would remove GPX block from %s\n", report.RCPath)
+		fmt.Println()
+		fmt.Print(report.NewContent)
+		return
+	}
+
+	if report.Profile != "" {
+		fmt.Printf("Removed profile %q from %s\n", report.Profile, report.RCPath)
+	} else {
+		fmt.Printf("Removed GPX block from %s\n", report.RCPath)
+	}
+	noteSymlink(report.RCPath, report.Target)
+	if report.BackupPath != "" {
+		fmt.Printf("Backup: %s\n", report.BackupPath)
+	}
+	fmt.Println("Note: variables stay set in running shells; open a new shell (or gpx off)")
+}
+
+func backupsCmd(args []string) {
+	fs := flag.NewFlagSet("backups", flag.ExitOnError)
+	shName := fs.String("shell", "", "shell type: sh, zsh, bash, fish, pwsh or nu (selects default rc file; default: detected)")
+	rc := fs.String("rc", "", "rc file path (overrides --shell default)")
+	format := formatFlag(fs)
+	_ = fs.Parse(args)
+	ensureFlagsBeforeArgs(fs.Args(), "backups")
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "error: backups takes no arguments")
+		os.Exit(2)
+	}
+
+	rcPath, d := rcPathOrExit(*rc, *shName)
+	backups, err := app.Backups(rcPath, d)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	if printDoc(*format, app.BackupsDoc(rcPath, backups)) {
+		return
+	}
+	fmt.Print(app.FormatBackups(rcPath, backups))
+}
+
+func restoreCmd(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	shName := fs.String("shell", "", "shell type: sh, zsh, bash, fish, pwsh or nu (selects default rc file; default: detected)")
+	rc := fs.String("rc", "", "rc file path (overrides --shell default)")
+	dryRun := fs.Bool("dry-run", false, "show a diff against the current rc file, but do not modify any file")
+	backup := fs.Bool("backup", false, "back up the current rc file before restoring")
+	replaceSymlink := replaceSymlinkFlag(fs)
+	_ = fs.Parse(args)
+	ensureFlagsBeforeArgs(fs.Args(), "restore")
+	if fs.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "error: restore takes at most one backup (id or path; see gpx backups)")
+		os.Exit(2)
+	}
+
+	path := *cfgPath
+	if path == "" {
+		path = defaultConfigPathOrExit()
+	}
+	rcPath, d := rcPathOrExit(*rc, *shName)
+
+	a := makeApp(path, *statePath)
+	report, err := a.RestoreRC(rcPath, fs.Arg(0), d, shell.ApplyOptions{
+		DryRun:         *dryRun,
+		Backup:         *backup,
+		ReplaceSymlink: *replaceSymlink,
+		Retention:      a.BackupRetention(),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	if *dryRun {
+		if report.Diff == "" {
+			fmt.Printf("Dry-run: %s already matches %s\n", report.RCPath, report.Backup.Path)
+			return
+		}
+		fmt.Printf("Dry-run: would restore %s from %s\n", report.RCPath, report.Backup.Path)
+		fmt.Println()
+		fmt.Print(report.Diff)
+		return
+	}
+
+	fmt.Printf("Restored %s from %s\n", report.RCPath, report.Backup.Path)
+	noteSymlink(report.RCPath, report.Target)
 	if report.BackupPath != "" {
 		fmt.Printf("Backup: %s\n", report.BackupPath)
 	}
 	fmt.Printf("Next: source %s (or restart shell)\n", report.RCPath)
 }
 
+// rcPathOrExit picks the rc file and its dialect from --rc and --shell:
+// without --shell, the --rc file extension picks the shell, then detection.
+func rcPathOrExit(rc, sh string) (string, envx.Dialect) {
+	if sh == "" && rc != "" {
+		sh = shell.ShellForRC(rc)
+	}
+	sh = shellOrDetect(sh)
+	d := dialectOrExit(sh)
+	if rc != "" {
+		return rc, d
+	}
+	p, err := shell.DefaultRC(sh)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	return p, d
+}
+
+func execCmd(args []string) {
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	_ = fs.Parse(args)
+
+	// everything after the profile is the command, flags included
+	rest := fs.Args()
+	if len(rest) < 1 {
+		fmt.Fprintln(os.Stderr, "error: missing profile name")
+		os.Exit(2)
+	}
+	profile := rest[0]
+	command := rest[1:]
+	if len(command) > 0 && command[0] == "--" {
+		command = command[1:]
+	}
+	if len(command) == 0 {
+		fmt.Fprintln(os.Stderr, "error: missing command. Try: gpx exec <profile> -- <command> [args ...]")
+		os.Exit(2)
+	}
+
+	path := *cfgPath
+	if path == "" {
+		path = defaultConfigPathOrExit()
+	}
+
+	a := makeApp(path, *statePath)
+	code, err := a.Exec(profile, command)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+func shellCmd(args []string) {
+	fs := flag.NewFlagSet("shell", flag.ExitOnError)
+	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	shPath := fs.String("shell", "", "shell to start (default: $SHELL)")
+	force := fs.Bool("force", false, "start even if the profile is already active")
+	_ = fs.Parse(args)
+	ensureFlagsBeforeArgs(fs.Args(), "shell")
+
+	rest := fs.Args()
+	if len(rest) < 1 {
+		fmt.Fprintln(os.Stderr, "error: missing profile name")
+		os.Exit(2)
+	}
+	profile := rest[0]
+
+	path := *cfgPath
+	if path == "" {
+		path = defaultConfigPathOrExit()
+	}
+
+	a := makeApp(path, *statePath)
+	code, err := a.Shell(profile, app.ShellOptions{Shell: *shPath, Force: *force})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+func shellInitCmd(args []string) {
+	fs := flag.NewFlagSet("shell-init", flag.ExitOnError)
+	install := fs.Bool("install", false, "add the init line to the rc file instead of printing the script")
+	hook := fs.Bool("hook", false, "also apply directory-bound profiles (.gpx) on cd")
+	rc := fs.String("rc", "", "rc file path for --install (default: detected shell's rc file)")
+	dryRun := fs.Bool("dry-run", false, "with --install: show what would be written, but do not modify any file")
+	backup := fs.Bool("backup", false, "with --install: create a backup of rc file before modifying it")
+	replaceSymlink := replaceSymlinkFlag(fs)
+	cfgPath := resolveConfigPath(fs) // with --install: backup retention
+	_ = fs.Parse(args)
+	ensureFlagsBeforeArgs(fs.Args(), "shell-init")
+
+	sh := ""
+	if rest := fs.Args(); len(rest) > 0 {
+		sh = rest[0]
+	}
+	sh = shellOrDetect(sh)
+
+	if !*install {
+		script, err := shell.InitScript(sh, *hook)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(2)
+		}
+		fmt.Print(script)
+		return
+	}
+
+	rcPath := *rc
+	if rcPath == "" {
+		p, err := shell.DefaultRC(sh)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		rcPath = p
+	}
+	path := *cfgPath
+	if path == "" {
+		path = defaultConfigPathOrExit()
+	}
+
+	report, err := app.InstallShellInit(sh, *hook, rcPath, shell.ApplyOptions{
+		DryRun:         *dryRun,
+		Backup:         *backup,
+		ReplaceSymlink: *replaceSymlink,
+		Retention:      makeApp(path, "").BackupRetention(),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry-run: would write shell-init block to %s\n", report.RCPath)
+		fmt.Println()
+		fmt.Print(report.NewContent)
+		return
+	}
+
+	fmt.Printf("Installed shell-init for %s to %s\n", sh, report.RCPath)
+	noteSymlink(report.RCPath, report.Target)
+	if report.BackupPath != "" {
+		fmt.Printf("Backup: %s\n", report.BackupPath)
+	}
+	fmt.Printf("Next: source %s (or restart shell)\n", report.RCPath)
+}
+
+func hookCmd(args []string) {
+	fs := flag.NewFlagSet("hook", flag.ExitOnError)
+	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	shName := dialectFlag(fs)
+	_ = fs.Parse(args)
+	d := dialectOrExit(*shName)
+
+	path := *cfgPath
+	if path == "" {
+		path = defaultConfigPathOrExit()
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gpx:", err)
+		os.Exit(1)
+	}
+
+	a := makeApp(path, *statePath)
+	res, err := a.Hook(cwd, d)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gpx:", err)
+		os.Exit(1)
+	}
+	if res.Warning != "" {
+		fmt.Fprintln(os.Stderr, res.Warning)
+	}
+	for _, ln := range res.Lines {
+		fmt.Println(ln)
+	}
+}
+
+func allowCmd(args []string, allow bool) {
+	name := "allow"
+	if !allow {
+		name = "deny"
+	}
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	_ = fs.Parse(args)
+	ensureFlagsBeforeArgs(fs.Args(), name)
+
+	target := "."
+	if fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+
+	// trust lives in the state file, which may depend on the config path
+	path := *cfgPath
+	if path == "" {
+		path = defaultConfigPathOrExit()
+	}
+	a := makeApp(path, *statePath)
+
+	// a project config layer (.gpx/config.*) is trusted the same way,
+	// unless a .gpx file is nearer
+	if proj, err := a.AllowTarget(target); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	} else if proj != nil {
+		projectFn, verb := a.AllowProject, "Allowed"
+		if !allow {
+			projectFn, verb = a.DenyProject, "Denied"
+		}
+		if _, err := projectFn(target); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s %s\n", verb, proj.Path)
+		return
+	}
+
+	if !allow {
+		f, err := a.DenyDir(target)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Denied %s\n", f.Path)
+		return
+	}
+	f, err := a.AllowDir(target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Allowed %s\n", f.Path)
+}
+
+func applyGoEnv(a app.App, profile string, opts shell.ApplyOptions) {
+	envPath, err := goenv.Path()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	report, err := a.ApplyProfileToGoEnv(profile, envPath, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	if !opts.Backup && !opts.DryRun {
+		fmt.Println("Note: no backup was created (use --backup to enable)")
+	}
+
+	if opts.DryRun {
+		fmt.Printf("Dry-run: would write go env file %s\n", report.RCPath)
+		fmt.Println()
+		fmt.Print(report.NewContent)
+		return
+	}
+
+	fmt.Printf("Applied profile %q to %s\n", profile, report.RCPath)
+	noteSymlink(report.RCPath, report.Target)
+	if report.BackupPath != "" {
+		fmt.Printf("Backup: %s\n", report.BackupPath)
+	}
+	fmt.Println("Note: applies to every go command, including IDEs and gopls; check with `go env`")
+}
+
 func profileCmd(args []string) {
 	if len(args) == 0 {
-		fmt.Fprintln(os.Stderr, "error: missing subcommand (add|rm|rename|show|set|unset)")
+		fmt.Fprintln(os.Stderr, "error: missing subcommand (add|rm|rename|show|extends|set|unset)")
 		os.Exit(2)
 	}
 
 	sub := args[0]
 	rest := args[1:]
 
-	// flags-first for all profile subcommands
-	ensureFlagsBeforeArgs(rest, "profile "+sub)
-
 	fs := flag.NewFlagSet("profile "+sub, flag.ExitOnError)
 	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	resolved := fs.Bool("resolved", false, "show: print variables with inheritance applied and their origin")
+	origin := fs.Bool("origin", false, "show: print the config file each variable comes from")
+	layer := fs.String("layer", "", "config layer to edit: system, team, user or project (default: user)")
+	force := fs.Bool("force", false, "rewrite the whole config file if an edit cannot be made in place (drops comments and layout)")
+	format := formatFlag(fs)
 	_ = fs.Parse(rest)
+	ensureFlagsBeforeArgs(fs.Args(), "profile "+sub)
 
 	path := *cfgPath
 	if path == "" {
 		path = defaultConfigPathOrExit()
 	}
-	a := makeApp(path)
+	a := makeApp(path, *statePath)
+	a.Layer = *layer
+	a.Rewrite = *force
 
 	argv := fs.Args()
 
@@ -362,8 +1050,7 @@ func profileCmd(args []string) {
 			os.Exit(2)
 		}
 		if err := a.AddProfile(argv[0]); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(1)
+			exitEdit(err)
 		}
 		fmt.Println("OK")
 	case "rm":
@@ -372,8 +1059,7 @@ func profileCmd(args []string) {
 			os.Exit(2)
 		}
 		if err := a.RemoveProfile(argv[0]); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(1)
+			exitEdit(err)
 		}
 		fmt.Println("OK")
 	case "rename":
@@ -382,8 +1068,7 @@ func profileCmd(args []string) {
 			os.Exit(2)
 		}
 		if err := a.RenameProfile(argv[0], argv[1]); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(1)
+			exitEdit(err)
 		}
 		fmt.Println("OK")
 	case "show":
@@ -391,12 +1076,48 @@ func profileCmd(args []string) {
 			fmt.Fprintln(os.Stderr, "error: profile show <name>")
 			os.Exit(2)
 		}
+		if *origin {
+			vars, err := a.ShowProfileOrigins(argv[0], *resolved)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				os.Exit(1)
+			}
+			if printDoc(*format, app.ProfileOriginsDoc(argv[0], *resolved, vars)) {
+				return
+			}
+			fmt.Print(app.FormatProfileOrigins(argv[0], *resolved, vars))
+			return
+		}
+		if *resolved {
+			vars, err := a.ShowResolvedProfile(argv[0])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				os.Exit(1)
+			}
+			if printDoc(*format, app.ResolvedProfileDoc(argv[0], vars)) {
+				return
+			}
+			fmt.Print(app.FormatResolvedProfileVars(argv[0], vars))
+			return
+		}
 		vars, err := a.ShowProfile(argv[0])
 		if err != nil {
 			fmt.Fprintln(os.Stderr, "error:", err)
 			os.Exit(1)
 		}
+		if printDoc(*format, app.ProfileDoc(argv[0], vars)) {
+			return
+		}
 		fmt.Print(app.FormatProfileVars(argv[0], vars))
+	case "extends":
+		if len(argv) < 1 {
+			fmt.Fprintln(os.Stderr, "error: profile extends <name> [parent ...]")
+			os.Exit(2)
+		}
+		if err := a.SetProfileExtends(argv[0], argv[1:]); err != nil {
+			exitEdit(err)
+		}
+		fmt.Println("OK")
 	case "set":
 		if len(argv) < 2 {
 			fmt.Fprintln(os.Stderr, "error: profile set <name> KEY=VALUE [KEY=VALUE ...]")
@@ -405,8 +1126,7 @@ func profileCmd(args []string) {
 		name := argv[0]
 		tokens := argv[1:]
 		if err := a.SetProfileVars(name, tokens); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(1)
+			exitEdit(err)
 		}
 		fmt.Println("OK")
 	case "unset":
@@ -417,8 +1137,7 @@ func profileCmd(args []string) {
 		name := argv[0]
 		keys := argv[1:]
 		if err := a.UnsetProfileVars(name, keys); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
-			os.Exit(1)
+			exitEdit(err)
 		}
 		fmt.Println("OK")
 	default:
@@ -427,6 +1146,111 @@ func profileCmd(args []string) {
 	}
 }
 
+// exitEdit reports a failed config edit, with a hint when only a full
+// rewrite of the file would save it.
+func exitEdit(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	if errors.Is(err, config.ErrRewrite) {
+		fmt.Fprintln(os.Stderr, "hint: make the change by hand, or rerun with --force to rewrite the file")
+	}
+	os.Exit(1)
+}
+
+func configCmd(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "error: missing subcommand (convert|migrate)")
+		os.Exit(2)
+	}
+
+	sub := args[0]
+	rest := args[1:]
+
+	fs := flag.NewFlagSet("config "+sub, flag.ExitOnError)
+	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	to := fs.String("to", "", "convert: target format: json, yaml or toml")
+	dryRun := fs.Bool("dry-run", false, "print the rewritten config without writing")
+	keep := fs.Bool("keep", false, "convert: keep the old config file")
+	_ = fs.Parse(rest)
+	ensureFlagsBeforeArgs(fs.Args(), "config "+sub)
+
+	path := *cfgPath
+	if path == "" {
+		path = defaultConfigPathOrExit()
+	}
+	a := makeApp(path, *statePath)
+
+	switch sub {
+	case "convert":
+		codec, err := config.CodecByName(*to)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error: config convert --to json|yaml|toml:", err)
+			os.Exit(2)
+		}
+		res, err := a.ConvertConfig(codec, app.ConvertOptions{DryRun: *dryRun, Keep: *keep})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		if *dryRun {
+			fmt.Printf("Dry-run: would write %s\n", res.To)
+			fmt.Println()
+			fmt.Print(string(res.Content))
+			return
+		}
+		fmt.Printf("Converted %s -> %s\n", res.From, res.To)
+		if !res.Removed {
+			fmt.Printf("Note: %s was kept (the default path is the first of config.json, config.yaml, config.yml, config.toml)\n", res.From)
+		}
+	case "migrate":
+		res, err := a.MigrateConfig(*dryRun)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		if len(res.Steps) == 0 {
+			fmt.Printf("Config %s is up to date (version %d)\n", path, res.To)
+			return
+		}
+		fmt.Println("Migrations:")
+		for _, s := range res.Steps {
+			fmt.Println("  " + s)
+		}
+		if *dryRun {
+			fmt.Printf("Dry-run: would write %s\n", path)
+			fmt.Println()
+			fmt.Print(string(res.Content))
+			return
+		}
+		fmt.Printf("Migrated %s\n", path)
+	default:
+		fmt.Fprintln(os.Stderr, "error: unknown config subcommand:", sub)
+		os.Exit(2)
+	}
+}
+
+func pathsCmd(args []string) {
+	fs := flag.NewFlagSet("paths", flag.ExitOnError)
+	cfgPath := resolveConfigPath(fs)
+	statePath := stateFlag(fs)
+	format := formatFlag(fs)
+	_ = fs.Parse(args)
+
+	path := *cfgPath
+	if path == "" {
+		path = defaultConfigPathOrExit()
+	}
+	p, err := makeApp(path, *statePath).Paths()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	if printDoc(*format, app.PathsDoc(p)) {
+		return
+	}
+	fmt.Print(app.FormatPaths(p))
+}
+
 func versionCmd() {
 	fmt.Printf("gpx %s (commit=%s, date=%s)\n", version, commit, date)
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// TestHelperProcess is not a test: the tests below re-run the test binary
+// with GPX_TEST_MAIN set, and it runs main on the arguments after "--".
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GPX_TEST_MAIN") == "" {
+		return
+	}
+	i := slices.Index(os.Args, "--")
+	os.Args = append([]string{"gpx"}, os.Args[i+1:]...)
+	main()
+	os.Exit(0)
+}
+
+// gpx runs gpx with args and returns its combined output and exit code.
+func gpx(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=^TestHelperProcess$", "--"}, args...)...)
+	cmd.Env = append(os.Environ(), "GPX_TEST_MAIN=1",
+		"XDG_CONFIG_HOME="+filepath.Join(dir, "config"), "XDG_STATE_HOME="+filepath.Join(dir, "state"),
+		"GPX_STATE=", "GPX_TEAM_CONFIG=")
+	out, err := cmd.CombinedOutput()
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return string(out), ee.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), 0
+}
+
+func TestExecCmd_CommandAfterProfile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no sh")
+	}
+	cfg := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(cfg, []byte(`{"version": 1, "profiles": {"public": {"GOPROXY": "https://proxy.golang.org"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"exec", "--config", cfg, "public", "sh", "-c", `test "$GOPROXY" = https://proxy.golang.org && exit 3`},
+		{"exec", "--config", cfg, "public", "--", "sh", "-c", `test "$GOPROXY" = https://proxy.golang.org && exit 3`},
+	} {
+		if out, code := gpx(t, args...); code != 3 {
+			t.Errorf("gpx %q: exit %d, want 3; output:\n%s", args, code, out)
+		}
+	}
+}
@@ -1,19 +1,72 @@
 package app
 
 import (
-	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/ZeraiGR/gpx/internal/config"
+	"github.com/ZeraiGR/gpx/internal/state"
 )
 
 type App struct {
 	ConfigPath string
+	// StatePath is the state file; empty means the one for ConfigPath
+	// (see StateStore).
+	StatePath string
+	// Layer is the config layer edits go to (config.LayerSystem, LayerTeam,
+	// LayerUser or LayerProject); empty means the user layer, i.e. ConfigPath.
+	Layer string
+	// Rewrite lets config edits re-encode the whole file when they cannot
+	// be made in place (see config.ErrRewrite).
+	Rewrite bool
 }
 
+// LoadConfig returns the merged config of all layers (see LoadLayers).
 func (a App) LoadConfig() (*config.Config, error) {
-	cfg, err := config.Load(a.ConfigPath)
+	l, err := a.LoadLayers()
 	if err != nil {
-		return nil, fmt.Errorf("load config: %w", err)
+		return nil, err
 	}
-	return cfg, nil
+	return l.Config, nil
+}
+
+// StateStore returns the state file of this App: StatePath if set, else
+// $GPX_STATE or the default state file for a config in config.Dir, and a
+// per-config file (state.ForConfig) for any other config.
+func (a App) StateStore() (state.Store, error) {
+	if a.StatePath != "" {
+		return state.Store{Path: a.StatePath}, nil
+	}
+	def, err := state.DefaultPath()
+	if err != nil {
+		return state.Store{}, err
+	}
+	if a.ConfigPath == "" || os.Getenv(state.PathEnv) != "" {
+		return state.Store{Path: def}, nil
+	}
+	// any format of the default config shares the state, so that
+	// gpx config convert keeps the active profile
+	dir, err := config.Dir()
+	if err != nil {
+		return state.Store{}, err
+	}
+	if abs, err := filepath.Abs(a.ConfigPath); err == nil && filepath.Dir(abs) == dir {
+		return state.Store{Path: def}, nil
+	}
+	p, err := state.ForConfig(a.ConfigPath)
+	if err != nil {
+		return state.Store{}, err
+	}
+	return state.Store{Path: p}, nil
+}
+
+// loadState reads the state best-effort: nil when it cannot be read, which
+// means no active profile and nothing trusted.
+func (a App) loadState() *state.State {
+	store, err := a.StateStore()
+	if err != nil {
+		return nil
+	}
+	st, _ := store.Load()
+	return st
 }
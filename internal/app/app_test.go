@@ -0,0 +1,113 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ZeraiGR/gpx/internal/envx"
+	"github.com/ZeraiGR/gpx/internal/state"
+)
+
+// stateEnv points the config and state directories into a temp dir and
+// returns both.
+func stateEnv(t *testing.T) (cfgDir, stateDir string) {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(root, "state"))
+	t.Setenv(state.PathEnv, "")
+	return filepath.Join(root, "config", "gpx"), filepath.Join(root, "state", "gpx")
+}
+
+func TestStateStore(t *testing.T) {
+	cfgDir, stateDir := stateEnv(t)
+	other := filepath.Join(t.TempDir(), "work.yaml")
+	shared := filepath.Join(stateDir, "state.json")
+
+	tests := []struct {
+		name      string
+		app       App
+		stateEnv  string
+		want      string // exact path, or
+		wantMatch string // a pattern for per-config files
+	}{
+		{name: "no config", app: App{}, want: shared},
+		{name: "default config", app: App{ConfigPath: filepath.Join(cfgDir, "config.json")}, want: shared},
+		{name: "default dir, other format", app: App{ConfigPath: filepath.Join(cfgDir, "config.toml")}, want: shared},
+		{
+			name:      "other config",
+			app:       App{ConfigPath: other},
+			wantMatch: `^` + regexp.QuoteMeta(filepath.Join(stateDir, "configs")+string(filepath.Separator)) + `work-[0-9a-f]{8}\.json$`,
+		},
+		{name: "GPX_STATE wins", app: App{ConfigPath: other}, stateEnv: "/tmp/gpx-state.json", want: "/tmp/gpx-state.json"},
+		{name: "StatePath wins", app: App{ConfigPath: other, StatePath: "/tmp/explicit.json"}, stateEnv: "/tmp/gpx-state.json", want: "/tmp/explicit.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(state.PathEnv, tt.stateEnv)
+			store, err := tt.app.StateStore()
+			if err != nil {
+				t.Fatalf("StateStore: %v", err)
+			}
+			if tt.want != "" && store.Path != tt.want {
+				t.Fatalf("path = %s, want %s", store.Path, tt.want)
+			}
+			if tt.wantMatch != "" && !regexp.MustCompile(tt.wantMatch).MatchString(store.Path) {
+				t.Fatalf("path = %s, want it to match %s", store.Path, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestStateStore_OtherConfigsDiffer(t *testing.T) {
+	stateEnv(t)
+	a, err := App{ConfigPath: filepath.Join(t.TempDir(), "config.json")}.StateStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := App{ConfigPath: filepath.Join(t.TempDir(), "config.json")}.StateStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Path == b.Path {
+		t.Fatalf("configs in different dirs share %s", a.Path)
+	}
+}
+
+func TestAllowAndHook_ShareState(t *testing.T) {
+	_, stateDir := stateEnv(t)
+	for _, k := range []string{DirEnv, DirSumEnv, DirSavedEnv, DirPendingEnv} {
+		t.Setenv(k, "")
+	}
+	a := testApp(t, `{"corp": {"GOPROXY": "https://proxy.corp"}}`)
+	a.StatePath = "" // derived from the config path, as on the command line
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".gpx"), []byte("corp\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.AllowDir(dir); err != nil {
+		t.Fatalf("AllowDir: %v", err)
+	}
+
+	res, err := App{ConfigPath: a.ConfigPath}.Hook(dir, envx.POSIX)
+	if err != nil {
+		t.Fatalf("Hook: %v", err)
+	}
+	if res.Warning != "" || !strings.Contains(strings.Join(res.Lines, "\n"), "GOPROXY") {
+		t.Fatalf("hook did not see the allowed file: warning %q, lines %q", res.Warning, res.Lines)
+	}
+	store, err := a.StateStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(store.Path, filepath.Join(stateDir, "configs")) {
+		t.Fatalf("state file %s is not per config", store.Path)
+	}
+	if _, err := os.Stat(store.Path); err != nil {
+		t.Fatalf("allow did not write the state file: %v", err)
+	}
+}
@@ -2,32 +2,114 @@ package app
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/ZeraiGR/gpx/internal/envx"
+	"github.com/ZeraiGR/gpx/internal/goenv"
 	"github.com/ZeraiGR/gpx/internal/shell"
-	"github.com/ZeraiGR/gpx/internal/state"
 )
 
 type ApplyReport struct {
-	RCPath      string
+	RCPath string
+	// Target is the file written: RCPath with symlinks resolved.
+	Target      string
 	BackupPath  string
 	WouldChange bool
 	NewContent  string
 }
 
-func (a App) ApplyProfileToRC(profile string, rcPath string, opts shell.ApplyOptions) (*ApplyReport, error) {
-	lines, err := a.UseProfile(profile)
+func (a App) ApplyProfileToRC(profile string, rcPath string, d envx.Dialect, opts shell.ApplyOptions) (*ApplyReport, error) {
+	cfg, err := a.LoadConfig()
 	if err != nil {
 		return nil, err
 	}
-	res, err := shell.ApplyToRC(rcPath, lines, opts)
+	p, err := resolveProfile(cfg, profile)
+	if err != nil {
+		return nil, err
+	}
+	// Only the unnamed block marks the profile active in shells that source
+	// it; named blocks are extra settings recorded in the state instead.
+	block := blockName(opts)
+	var lines []string
+	if block == "" {
+		lines, err = profileExportLines(d, profile, p)
+	} else {
+		lines, err = envx.Vars(p).ExportLinesFor(d)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res *shell.ApplyResult
+	err = withLockUnless(opts.DryRun, rcPath, func() (err error) {
+		res, err = shell.ApplyToRC(rcPath, lines, opts)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("apply to rc: %w", err)
 	}
 
-	_ = state.SetActiveProfile(profile)
+	if !opts.DryRun {
+		if block == "" {
+			a.setActiveProfile(profile)
+		} else {
+			a.setBlockProfile(rcPath, block, profile)
+		}
+	}
+
+	return &ApplyReport{
+		RCPath:      res.RCPath,
+		Target:      res.Target,
+		BackupPath:  res.BackupPath,
+		WouldChange: res.WouldChange,
+		NewContent:  res.NewContent,
+	}, nil
+}
+
+// blockName returns the name of the GPX block opts selects (see
+// shell.Markers.Named), "" for the unnamed one.
+func blockName(opts shell.ApplyOptions) string {
+	m := opts.BlockMarkers()
+	if m == shell.ProfileMarkers {
+		return ""
+	}
+	name, _ := strings.CutPrefix(m.Begin, shell.ProfileMarkers.Begin+" ")
+	return name
+}
+
+// ApplyProfileToGoEnv writes the profile into the go command's env file
+// (see goenv.Path), rewriting only the keys the profile owns.
+// Profiles with variables the go env file cannot hold are refused.
+func (a App) ApplyProfileToGoEnv(profile string, envPath string, opts shell.ApplyOptions) (*ApplyReport, error) {
+	cfg, err := a.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	p, err := resolveProfile(cfg, profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := goenv.Validate(p); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", profile, err)
+	}
+
+	var res *shell.ApplyResult
+	err = withLockUnless(opts.DryRun, envPath, func() (err error) {
+		res, err = shell.UpdateFile(envPath, func(old string) (string, error) {
+			return goenv.Upsert(old, p), nil
+		}, opts)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("apply to go env: %w", err)
+	}
+
+	if !opts.DryRun {
+		a.setActiveProfile(profile)
+	}
 
 	return &ApplyReport{
 		RCPath:      res.RCPath,
+		Target:      res.Target,
 		BackupPath:  res.BackupPath,
 		WouldChange: res.WouldChange,
 		NewContent:  res.NewContent,
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZeraiGR/gpx/internal/envx"
+	"github.com/ZeraiGR/gpx/internal/shell"
+	"github.com/ZeraiGR/gpx/internal/state"
+)
+
+func TestApplyToRC_NamedBlockKeepsActiveProfile(t *testing.T) {
+	a := testApp(t, `{"corp": {"GOPROXY": "https://proxy.corp"}, "ci": {"GOFLAGS": "-mod=vendor"}}`)
+	rc := filepath.Join(t.TempDir(), ".bashrc")
+	named := shell.ApplyOptions{Markers: shell.ProfileMarkers.Named("ci")}
+
+	if _, err := a.ApplyProfileToRC("corp", rc, envx.POSIX, shell.ApplyOptions{}); err != nil {
+		t.Fatalf("apply corp: %v", err)
+	}
+	if _, err := a.ApplyProfileToRC("ci", rc, envx.POSIX, named); err != nil {
+		t.Fatalf("apply ci: %v", err)
+	}
+
+	b, err := os.ReadFile(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), ActiveProfileEnv+"="); n != 1 {
+		t.Fatalf("%s exported %d times, want only in the unnamed block:\n%s", ActiveProfileEnv, n, b)
+	}
+	st := a.loadState()
+	if st.ActiveProfile != "corp" {
+		t.Fatalf("active profile = %q, want corp", st.ActiveProfile)
+	}
+	blocks, err := a.RCBlocks(rc, envx.POSIX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 || blocks[0].Profile != "corp" || blocks[1].Name != "ci" || blocks[1].Profile != "ci" {
+		t.Fatalf("blocks = %+v", blocks)
+	}
+
+	rep, err := a.UnapplyFromRC(rc, envx.POSIX, named)
+	if err != nil {
+		t.Fatalf("unapply ci: %v", err)
+	}
+	if rep.Profile != "ci" {
+		t.Fatalf("unapplied profile = %q, want ci", rep.Profile)
+	}
+	st = a.loadState()
+	if st.ActiveProfile != "corp" || st.BlockProfile(rcKey(rc), "ci") != "" {
+		t.Fatalf("state after unapply = %+v", st)
+	}
+}
+
+func TestLockTarget_FollowsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state")
+	t.Setenv("XDG_STATE_HOME", stateDir)
+	t.Setenv(state.PathEnv, "")
+	real := filepath.Join(dir, "dotfiles", "bashrc")
+	if err := os.MkdirAll(filepath.Dir(real), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rc := filepath.Join(dir, ".bashrc")
+	if err := os.Symlink(real, rc); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	other := filepath.Join(dir, ".bashrc-link")
+	if err := os.Symlink(filepath.Join("dotfiles", "bashrc"), other); err != nil {
+		t.Fatal(err)
+	}
+
+	got := lockTarget(rc)
+	if got != lockTarget(other) {
+		t.Fatalf("links to one file lock %s and %s", got, lockTarget(other))
+	}
+	if !strings.HasPrefix(got, filepath.Join(stateDir, "gpx", "locks")+string(filepath.Separator)) {
+		t.Fatalf("lockTarget(%s) = %s, want a file in the state dir", rc, got)
+	}
+	if plain := filepath.Join(dir, "zshrc"); lockTarget(plain) != plain {
+		t.Fatalf("lockTarget changed a plain path")
+	}
+
+	if err := withLock(rc, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(real))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("lock left in the dotfiles dir: %v", entries)
+	}
+}
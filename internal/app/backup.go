@@ -0,0 +1,148 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ZeraiGR/gpx/internal/config"
+	"github.com/ZeraiGR/gpx/internal/diffx"
+	"github.com/ZeraiGR/gpx/internal/envx"
+	"github.com/ZeraiGR/gpx/internal/shell"
+	"github.com/ZeraiGR/gpx/internal/state"
+)
+
+// BackupEntry is a backup of an rc file with the GPX blocks it holds,
+// i.e. the profiles applied when it was taken.
+type BackupEntry struct {
+	shell.Backup
+	Blocks []RCBlock
+}
+
+// Backups lists the backups of rcPath, newest first (see shell.Backups).
+func Backups(rcPath string, d envx.Dialect) ([]BackupEntry, error) {
+	backups, err := shell.Backups(rcPath)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]BackupEntry, 0, len(backups))
+	for _, b := range backups {
+		blocks, err := rcBlocks(b.Path, d)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, BackupEntry{Backup: b, Blocks: blocks})
+	}
+	return out, nil
+}
+
+// BackupRetention returns the backup policy of the config as a
+// shell.Retention. Only the layers the user controls count: a project
+// layer comes with a repository and must not delete backups. It is
+// best-effort: without a readable policy, all backups are kept.
+func (a App) BackupRetention() shell.Retention {
+	l, err := a.LoadLayers()
+	if err != nil {
+		return shell.Retention{}
+	}
+	var policy *config.BackupPolicy
+	for _, layer := range l.Layers {
+		if layer.Name != config.LayerProject && layer.Config.Backup != nil {
+			policy = layer.Config.Backup
+		}
+	}
+	if policy == nil {
+		return shell.Retention{}
+	}
+	maxAge, err := policy.MaxAgeDuration()
+	if err != nil {
+		return shell.Retention{}
+	}
+	return shell.Retention{Keep: policy.Keep, MaxAge: maxAge}
+}
+
+type RestoreReport struct {
+	ApplyReport
+	Backup shell.Backup
+	// Diff is the unified diff from the current rc file to the backup.
+	Diff string
+}
+
+// RestoreRC replaces rcPath with one of its backups (see shell.FindBackup),
+// atomically and under the rc lock, and records the profile of the restored
+// GPX block as the active one.
+func (a App) RestoreRC(rcPath, ref string, d envx.Dialect, opts shell.ApplyOptions) (*RestoreReport, error) {
+	b, err := shell.FindBackup(rcPath, ref)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(b.Path)
+	if err != nil {
+		return nil, fmt.Errorf("read backup %s: %w", b.Path, err)
+	}
+
+	rep := &RestoreReport{Backup: b}
+	current := ""
+	err = withLockUnless(opts.DryRun, rcPath, func() error {
+		res, err := shell.UpdateFile(rcPath, func(old string) (string, error) {
+			current = old
+			rep.Diff = diffx.Unified(rcPath, b.Path, old, string(content))
+			return string(content), nil
+		}, opts)
+		if err != nil {
+			return err
+		}
+		rep.ApplyReport = ApplyReport{
+			RCPath:      res.RCPath,
+			Target:      res.Target,
+			BackupPath:  res.BackupPath,
+			WouldChange: res.WouldChange,
+			NewContent:  res.NewContent,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("restore rc: %w", err)
+	}
+
+	if !opts.DryRun {
+		// best-effort, like recording it in apply
+		if p := blockProfile(string(content), d); p != "" {
+			a.setActiveProfile(p)
+		} else if p := blockProfile(current, d); p != "" {
+			_ = a.updateState(func(s state.Store) error { return s.ClearActiveProfile(p) })
+		}
+	}
+	return rep, nil
+}
+
+func FormatBackups(rcPath string, backups []BackupEntry) string {
+	if len(backups) == 0 {
+		return fmt.Sprintf("No backups of %s (gpx apply --backup makes one)\n", rcPath)
+	}
+	out := fmt.Sprintf("Backups of %s (newest first):\n", rcPath)
+	for _, b := range backups {
+		out += fmt.Sprintf("  %s  %8d B  %-20s %s\n", b.ID, b.Size, backupProfiles(b.Blocks), b.Path)
+	}
+	return out
+}
+
+// backupProfiles describes the blocks of a backup: "corp" for the unnamed
+// block, "creds=corp-creds" for named ones.
+func backupProfiles(blocks []RCBlock) string {
+	if len(blocks) == 0 {
+		return "(no GPX block)"
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		p := b.Profile
+		if p == "" {
+			p = "(unknown)"
+		}
+		if b.Name != "" {
+			p = b.Name + "=" + p
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZeraiGR/gpx/internal/config"
+)
+
+type ConvertOptions struct {
+	DryRun bool
+	Keep   bool // keep the old file next to the new one
+}
+
+type ConvertResult struct {
+	From    string
+	To      string
+	Content []byte
+	Removed bool
+}
+
+// ConvertConfig rewrites the user config file in another format, upgraded
+// to the current schema version. Only that file is converted: profiles of
+// the other layers stay where they are. The new file gets the codec's
+// extension; the old one is removed unless opts.Keep is set.
+func (a App) ConvertConfig(to config.Codec, opts ConvertOptions) (*ConvertResult, error) {
+	var res *ConvertResult
+	err := withLockUnless(opts.DryRun, a.ConfigPath, func() (err error) {
+		res, err = a.convertConfig(to, opts)
+		return err
+	})
+	return res, err
+}
+
+func (a App) convertConfig(to config.Codec, opts ConvertOptions) (*ConvertResult, error) {
+	cfg, err := config.LoadLayer(a.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+
+	dst := strings.TrimSuffix(a.ConfigPath, filepath.Ext(a.ConfigPath)) + to.Ext()
+	if dst == a.ConfigPath {
+		return nil, fmt.Errorf("config %s is already %s", a.ConfigPath, to.Name())
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return nil, fmt.Errorf("convert config: %s already exists", dst)
+	}
+
+	b, err := to.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("convert config: %w", err)
+	}
+	res := &ConvertResult{From: a.ConfigPath, To: dst, Content: b}
+	if opts.DryRun {
+		return res, nil
+	}
+
+	if err := config.SaveAs(dst, cfg, to); err != nil {
+		return nil, fmt.Errorf("convert config: %w", err)
+	}
+	if !opts.Keep {
+		if err := os.Remove(a.ConfigPath); err != nil {
+			return nil, fmt.Errorf("convert config: remove %s: %w", a.ConfigPath, err)
+		}
+		res.Removed = true
+	}
+	return res, nil
+}
+
+// MigrateConfig upgrades the config file to the current schema version.
+func (a App) MigrateConfig(dryRun bool) (*config.MigrateResult, error) {
+	var res *config.MigrateResult
+	err := withLockUnless(dryRun, a.ConfigPath, func() (err error) {
+		res, err = config.Migrate(a.ConfigPath, dryRun)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("migrate config: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZeraiGR/gpx/internal/config"
+)
+
+func TestConvertConfig_OnlyUserLayer(t *testing.T) {
+	a := testApp(t, `{}`)
+	// a version 0 file: no version field
+	if err := os.WriteFile(a.ConfigPath, []byte(`{"profiles": {"mine": {"GOPROXY": "direct"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sysDir := config.SystemDir
+	config.SystemDir = t.TempDir()
+	t.Cleanup(func() { config.SystemDir = sysDir })
+	team := filepath.Join(t.TempDir(), "team.json")
+	if err := os.WriteFile(team, []byte(`{"version": 1, "profiles": {"teamprof": {"GOFLAGS": "-v"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.TeamEnv, team)
+
+	res, err := a.ConvertConfig(config.TOML, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("ConvertConfig: %v", err)
+	}
+	if !res.Removed {
+		t.Fatal("the JSON file was kept")
+	}
+	cfg, err := config.Load(res.To)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Profiles["teamprof"]; ok {
+		t.Fatalf("team profile written into the user config: %+v", cfg.Profiles)
+	}
+	if cfg.Profiles["mine"]["GOPROXY"] != "direct" || cfg.Version != config.CurrentVersion {
+		t.Fatalf("converted config = %+v", cfg)
+	}
+	if team2, err := config.Load(team); err != nil || team2.Profiles["teamprof"] == nil {
+		t.Fatalf("team layer changed: %+v, %v", team2, err)
+	}
+}
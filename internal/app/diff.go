@@ -19,9 +19,9 @@ func (a App) DiffProfile(profile string) ([]DiffRow, error) {
 	if err != nil {
 		return nil, err
 	}
-	p, ok := cfg.Profiles[profile]
-	if !ok {
-		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, profile)
+	p, err := resolveProfile(cfg, profile)
+	if err != nil {
+		return nil, err
 	}
 
 	keys := make([]string, 0, len(p))
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ZeraiGR/gpx/internal/config"
+	"github.com/ZeraiGR/gpx/internal/dotgpx"
+	"github.com/ZeraiGR/gpx/internal/envx"
+	"github.com/ZeraiGR/gpx/internal/state"
+)
+
+// Shell variables maintained by `gpx hook`.
+const (
+	// DirEnv is the path of the .gpx file applied in this shell.
+	DirEnv = "GPX_DIR"
+	// DirSumEnv is the checksum of that file, so edits are noticed.
+	DirSumEnv = "GPX_DIR_SUM"
+	// DirSavedEnv is the snapshot of values from before the .gpx was applied.
+	DirSavedEnv = "GPX_DIR_SAVED"
+	// DirPendingEnv remembers a not-allowed .gpx that was already reported.
+	DirPendingEnv = "GPX_DIR_PENDING"
+)
+
+var ErrNoDirProfile = errors.New("no .gpx file found")
+
+// DirBinding is the .gpx file that applies to a directory.
+type DirBinding struct {
+	File    *dotgpx.File
+	Allowed bool
+}
+
+// DirProfile finds the nearest .gpx file for dir. It returns nil if there is none.
+func (a App) DirProfile(dir string) (*DirBinding, error) {
+	path, err := dotgpx.Find(dir)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	f, err := dotgpx.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	st := a.loadState()
+	return &DirBinding{File: f, Allowed: st.IsAllowed(f.Path, f.Sum)}, nil
+}
+
+type HookResult struct {
+	Lines []string
+	// Warning is meant for stderr, e.g. about a .gpx file that is not allowed yet.
+	Warning string
+}
+
+// Hook computes the lines that bring the shell in sync with the .gpx file
+// effective for dir: restoring the values saved when the previous one was
+// applied and applying the new one. It is meant to run on every cd/prompt,
+// so it prints nothing when nothing changed.
+func (a App) Hook(dir string, d envx.Dialect) (*HookResult, error) {
+	b, err := a.DirProfile(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &HookResult{}
+	target, sum := "", ""
+	pending := os.Getenv(DirPendingEnv)
+	switch {
+	case b != nil && b.Allowed:
+		target, sum = b.File.Path, b.File.Sum
+	case b != nil:
+		if mark := b.File.Path + ":" + b.File.Sum; pending != mark {
+			res.Warning = fmt.Sprintf("gpx: %s is not allowed; run `gpx allow` to trust it", b.File.Path)
+			res.Lines = append(res.Lines, d.Export(DirPendingEnv, mark))
+		}
+	}
+	if pending != "" && (b == nil || b.Allowed) {
+		res.Lines = append(res.Lines, d.Unset(DirPendingEnv))
+	}
+
+	cur := os.Getenv(DirEnv)
+	if target == cur && sum == os.Getenv(DirSumEnv) {
+		return res, nil
+	}
+
+	// Values from before any .gpx was applied; they stay the baseline
+	// when moving from one bound directory to another.
+	base := envx.Snapshot{}
+	if cur != "" {
+		if saved := os.Getenv(DirSavedEnv); saved != "" {
+			if base, err = envx.DecodeSnapshot(saved); err != nil {
+				return nil, fmt.Errorf("%s: %w", DirSavedEnv, err)
+			}
+		}
+		lines, err := base.RestoreLines(d)
+		if err != nil {
+			return nil, fmt.Errorf("render restore: %w", err)
+		}
+		res.Lines = append(res.Lines, lines...)
+		if target == "" {
+			res.Lines = append(res.Lines, d.Unset(DirEnv), d.Unset(DirSumEnv), d.Unset(DirSavedEnv))
+			return res, nil
+		}
+	}
+
+	cfg, err := a.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	vars, err := dirVars(cfg, b.File)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := envx.Snapshot{}
+	for k := range vars {
+		if v, ok := base[k]; ok {
+			snap[k] = v
+		}
+	}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	snap.Capture(keys)
+	enc, err := snap.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	vars[DirEnv] = target
+	vars[DirSumEnv] = sum
+	vars[DirSavedEnv] = enc
+	lines, err := vars.ExportLinesFor(d)
+	if err != nil {
+		return nil, fmt.Errorf("render exports: %w", err)
+	}
+	res.Lines = append(res.Lines, lines...)
+	return res, nil
+}
+
+// dirVars resolves a .gpx file: its profile (with inheritance and the
+// ActiveProfileEnv and KeysEnv markers) overridden by inline variables.
+func dirVars(cfg *config.Config, f *dotgpx.File) (envx.Vars, error) {
+	vars := envx.Vars{}
+	if f.Profile != "" {
+		p, err := resolveProfile(cfg, f.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Path, err)
+		}
+		for k, v := range p {
+			vars[k] = v
+		}
+	}
+	for k, v := range f.Vars {
+		vars[k] = v
+	}
+	if f.Profile != "" {
+		markProfile(vars, f.Profile)
+	}
+	return vars, nil
+}
+
+// AllowDir trusts the current content of a .gpx file. path may be the file
+// itself or a directory to search from.
+func (a App) AllowDir(path string) (*dotgpx.File, error) {
+	f, err := findDirFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.updateState(func(s state.Store) error { return s.Allow(f.Path, f.Sum) }); err != nil {
+		return nil, fmt.Errorf("save state: %w", err)
+	}
+	return f, nil
+}
+
+// DenyDir removes a .gpx file from the trusted list.
+func (a App) DenyDir(path string) (*dotgpx.File, error) {
+	f, err := findDirFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.updateState(func(s state.Store) error { return s.Deny(f.Path) }); err != nil {
+		return nil, fmt.Errorf("save state: %w", err)
+	}
+	return f, nil
+}
+
+func findDirFile(path string) (*dotgpx.File, error) {
+	if st, err := os.Stat(path); err == nil && !st.IsDir() {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("abs %s: %w", path, err)
+		}
+		return dotgpx.Load(abs)
+	}
+	found, err := dotgpx.Find(path)
+	if err != nil {
+		return nil, err
+	}
+	if found == "" {
+		return nil, fmt.Errorf("%w from %s", ErrNoDirProfile, path)
+	}
+	return dotgpx.Load(found)
+}
@@ -0,0 +1,272 @@
+package app
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ZeraiGR/gpx/internal/config"
+	"github.com/ZeraiGR/gpx/internal/output"
+	"github.com/ZeraiGR/gpx/internal/shell"
+)
+
+// Documents printed by --format json|yaml. Field names and meaning are part of
+// the CLI contract: additions are fine, anything else bumps output.SchemaVersion.
+// Values that may be absent (not set, no such file) are null rather than "".
+
+type ListDocument struct {
+	Version  int         `json:"version"`
+	Kind     string      `json:"kind"` // "list"
+	Profiles []ListEntry `json:"profiles"`
+}
+
+type ListEntry struct {
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+	Dir    bool   `json:"dir"`
+}
+
+type StatusDocument struct {
+	Version   int              `json:"version"`
+	Kind      string           `json:"kind"` // "status"
+	Shell     ShellEntry       `json:"shell"`
+	RCPath    *string          `json:"rc_path"`
+	GoEnvPath *string          `json:"goenv_path"`
+	RCBlocks  []StatusBlock    `json:"rc_blocks"`
+	Variables []StatusVariable `json:"variables"`
+}
+
+type StatusBlock struct {
+	Name      *string `json:"name"` // null for the unnamed block
+	Profile   *string `json:"profile"`
+	BeginLine int     `json:"begin_line"`
+	EndLine   int     `json:"end_line"`
+}
+
+type ShellEntry struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+type StatusVariable struct {
+	Key   string  `json:"key"`
+	Env   *string `json:"env"`
+	GoEnv *string `json:"goenv"`
+	RC    *string `json:"rc"`
+	// RCError is set when the rc file could not be read.
+	RCError *string `json:"rc_error,omitempty"`
+	// Default is null for variables the go command does not read.
+	Default *string `json:"default"`
+	// Source is "env", "goenv", "default" or null; Effective is its value.
+	Source    *string `json:"source"`
+	Effective *string `json:"effective"`
+}
+
+type DiffDocument struct {
+	Version   int            `json:"version"`
+	Kind      string         `json:"kind"` // "diff"
+	Profile   string         `json:"profile"`
+	Variables []DiffVariable `json:"variables"`
+}
+
+type DiffVariable struct {
+	Key     string  `json:"key"`
+	Current *string `json:"current"`
+	Target  string  `json:"target"`
+	Changed bool    `json:"changed"`
+}
+
+type ProfileDocument struct {
+	Version   int               `json:"version"`
+	Kind      string            `json:"kind"` // "profile"
+	Name      string            `json:"name"`
+	Resolved  bool              `json:"resolved"`
+	Variables []ProfileVariable `json:"variables"`
+}
+
+type ProfileVariable struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Origin is the profile that defined the key; only with Resolved.
+	Origin string `json:"origin,omitempty"`
+	// Layer and File name the config file the key was read from; only
+	// with --origin.
+	Layer string `json:"layer,omitempty"`
+	File  string `json:"file,omitempty"`
+}
+
+type PathsDocument struct {
+	Version int          `json:"version"`
+	Kind    string       `json:"kind"` // "paths"
+	Config  string       `json:"config"`
+	State   string       `json:"state"`
+	System  *string      `json:"system"`
+	Team    *string      `json:"team"`
+	Project *ProjectPath `json:"project"`
+	GoEnv   *string      `json:"goenv"`
+}
+
+type BackupsDocument struct {
+	Version int              `json:"version"`
+	Kind    string           `json:"kind"` // "backups"
+	RCPath  string           `json:"rc_path"`
+	Backups []BackupEntryDoc `json:"backups"`
+}
+
+type BackupEntryDoc struct {
+	ID       string        `json:"id"`
+	Path     string        `json:"path"`
+	Time     string        `json:"time"` // RFC 3339
+	Size     int64         `json:"size"`
+	RCBlocks []StatusBlock `json:"rc_blocks"`
+}
+
+type ProjectPath struct {
+	Path    string `json:"path"`
+	Allowed bool   `json:"allowed"`
+}
+
+func ListDoc(items []ProfileItem) ListDocument {
+	doc := ListDocument{Version: output.SchemaVersion, Kind: "list", Profiles: []ListEntry{}}
+	for _, it := range items {
+		doc.Profiles = append(doc.Profiles, ListEntry{Name: it.Name, Active: it.Active, Dir: it.Dir})
+	}
+	return doc
+}
+
+func StatusDoc(rows []StatusRow, blocks []RCBlock, det shell.Detection, rcPath, goEnvPath string) StatusDocument {
+	doc := StatusDocument{
+		Version:   output.SchemaVersion,
+		Kind:      "status",
+		Shell:     ShellEntry{Name: det.Shell, Source: det.Source},
+		RCPath:    strPtr(rcPath, rcPath != ""),
+		GoEnvPath: strPtr(goEnvPath, goEnvPath != ""),
+		Variables: []StatusVariable{},
+	}
+	doc.RCBlocks = statusBlocks(blocks)
+	for _, r := range rows {
+		doc.Variables = append(doc.Variables, StatusVariable{
+			Key:       r.Key,
+			Env:       strPtr(r.Value, r.Set),
+			GoEnv:     strPtr(r.GoEnv, r.HasGoEnv),
+			RC:        strPtr(r.RC, r.HasRC),
+			RCError:   strPtr(r.RCErr, r.RCErr != ""),
+			Default:   strPtr(r.Default, r.GoKey),
+			Source:    strPtr(r.Source, r.Source != ""),
+			Effective: strPtr(r.Effective, r.Source != ""),
+		})
+	}
+	return doc
+}
+
+func statusBlocks(blocks []RCBlock) []StatusBlock {
+	out := []StatusBlock{}
+	for _, b := range blocks {
+		out = append(out, StatusBlock{
+			Name:      strPtr(b.Name, b.Name != ""),
+			Profile:   strPtr(b.Profile, b.Profile != ""),
+			BeginLine: b.BeginLine,
+			EndLine:   b.EndLine,
+		})
+	}
+	return out
+}
+
+func BackupsDoc(rcPath string, backups []BackupEntry) BackupsDocument {
+	doc := BackupsDocument{Version: output.SchemaVersion, Kind: "backups", RCPath: rcPath, Backups: []BackupEntryDoc{}}
+	for _, b := range backups {
+		doc.Backups = append(doc.Backups, BackupEntryDoc{
+			ID:       b.ID,
+			Path:     b.Path,
+			Time:     b.Time.Format(time.RFC3339),
+			Size:     b.Size,
+			RCBlocks: statusBlocks(b.Blocks),
+		})
+	}
+	return doc
+}
+
+func DiffDoc(profile string, rows []DiffRow) DiffDocument {
+	doc := DiffDocument{Version: output.SchemaVersion, Kind: "diff", Profile: profile, Variables: []DiffVariable{}}
+	for _, r := range rows {
+		doc.Variables = append(doc.Variables, DiffVariable{
+			Key:     r.Key,
+			Current: strPtr(r.Current, r.HasCurr),
+			Target:  r.Target,
+			Changed: r.Changed,
+		})
+	}
+	return doc
+}
+
+func ProfileDoc(name string, vars map[string]string) ProfileDocument {
+	doc := ProfileDocument{Version: output.SchemaVersion, Kind: "profile", Name: name, Variables: []ProfileVariable{}}
+	for _, k := range sortedKeys(vars) {
+		doc.Variables = append(doc.Variables, ProfileVariable{Key: k, Value: vars[k]})
+	}
+	return doc
+}
+
+func ResolvedProfileDoc(name string, vars map[string]config.ResolvedVar) ProfileDocument {
+	doc := ProfileDocument{Version: output.SchemaVersion, Kind: "profile", Name: name, Resolved: true, Variables: []ProfileVariable{}}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		doc.Variables = append(doc.Variables, ProfileVariable{Key: k, Value: vars[k].Value, Origin: vars[k].Origin})
+	}
+	return doc
+}
+
+// ProfileOriginsDoc is ProfileDoc (or ResolvedProfileDoc) with the layer
+// of each variable.
+func ProfileOriginsDoc(name string, resolved bool, vars map[string]OriginVar) ProfileDocument {
+	doc := ProfileDocument{Version: output.SchemaVersion, Kind: "profile", Name: name, Resolved: resolved, Variables: []ProfileVariable{}}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := vars[k]
+		pv := ProfileVariable{Key: k, Value: v.Value, Layer: v.Layer, File: v.Path}
+		if resolved {
+			pv.Origin = v.Profile
+		}
+		doc.Variables = append(doc.Variables, pv)
+	}
+	return doc
+}
+
+func PathsDoc(p Paths) PathsDocument {
+	doc := PathsDocument{
+		Version: output.SchemaVersion,
+		Kind:    "paths",
+		Config:  p.Config,
+		State:   p.State,
+		System:  strPtr(p.System, p.System != ""),
+		Team:    strPtr(p.Team, p.Team != ""),
+		GoEnv:   strPtr(p.GoEnv, p.GoEnv != ""),
+	}
+	if p.Project != nil {
+		doc.Project = &ProjectPath{Path: p.Project.Path, Allowed: p.Project.Allowed}
+	}
+	return doc
+}
+
+func strPtr(s string, ok bool) *string {
+	if !ok {
+		return nil
+	}
+	return &s
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,96 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	"github.com/ZeraiGR/gpx/internal/envx"
+)
+
+// ProfileEnviron returns the current process environment with the profile
+// applied the same way `gpx use` would apply it to a shell.
+func (a App) ProfileEnviron(name string) ([]string, error) {
+	cfg, err := a.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	p, err := resolveProfile(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := envx.Vars{}
+	for k, v := range p {
+		vars[k] = v
+	}
+	markProfile(vars, name)
+
+	return envx.Overlay(os.Environ(), vars, staleKeys(cfg, p)), nil
+}
+
+// Exec runs argv under the profile with stdio passed through
+// and returns the child's exit code. Where the platform allows (see
+// replaceProcess), gpx is replaced by the command instead, so signals and
+// the exit status are the command's own.
+func (a App) Exec(name string, argv []string) (int, error) {
+	if len(argv) == 0 {
+		return 0, fmt.Errorf("no command given")
+	}
+	env, err := a.ProfileEnviron(name)
+	if err != nil {
+		return 0, err
+	}
+	if err := replaceProcess(argv, env); err != nil {
+		return 0, err
+	}
+	return run(argv, env)
+}
+
+// run starts argv and waits for it. Interrupts are left to the child
+// (it shares our terminal), gpx itself just outlives it; the signals in
+// forwardSignals, which usually reach gpx alone, are passed on.
+// A child killed by a signal yields 128+signal, as in a shell.
+func run(argv []string, env []string) (int, error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, append([]os.Signal{os.Interrupt}, forwardSignals...)...)
+	defer signal.Stop(sig)
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("start %s: %w", argv[0], err)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case s := <-sig:
+				if s != os.Interrupt {
+					_ = cmd.Process.Signal(s)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := cmd.Wait(); err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			if code := ee.ExitCode(); code >= 0 {
+				return code, nil
+			}
+			return signalExitCode(ee), nil
+		}
+		return 0, fmt.Errorf("wait %s: %w", argv[0], err)
+	}
+	return 0, nil
+}
@@ -0,0 +1,20 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package app
+
+import (
+	"os"
+	"os/exec"
+)
+
+// forwardSignals is empty: other platforms cannot signal a child.
+var forwardSignals []os.Signal
+
+// replaceProcess is not available; Exec runs the command as a child.
+func replaceProcess(argv, env []string) error {
+	return nil
+}
+
+func signalExitCode(ee *exec.ExitError) int {
+	return 1
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// TestHelperProcess is not a test: the tests below re-run the test binary
+// with GPX_TEST_HELPER set, so that Exec can replace the process.
+func TestHelperProcess(t *testing.T) {
+	switch os.Getenv("GPX_TEST_HELPER") {
+	case "":
+		return
+	case "exec":
+		// gpx exec corp <this binary>, which then runs the mode in GPX_TEST_CMD
+		a := App{ConfigPath: os.Getenv("GPX_TEST_CONFIG"), StatePath: os.Getenv("GPX_TEST_STATE")}
+		os.Setenv("GPX_TEST_HELPER", os.Getenv("GPX_TEST_CMD"))
+		code, err := a.Exec("corp", []string{os.Args[0], "-test.run=^TestHelperProcess$"})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(100)
+		}
+		os.Exit(code)
+	case "env":
+		for _, k := range []string{"GOPROXY", "GOFLAGS", ActiveProfileEnv, KeysEnv} {
+			v, ok := os.LookupEnv(k)
+			fmt.Printf("%s=%s %v\n", k, v, ok)
+		}
+		os.Exit(3)
+	case "term":
+		p, _ := os.FindProcess(os.Getpid())
+		_ = p.Signal(syscall.SIGTERM)
+		select {}
+	}
+}
+
+// execHelper runs Exec for profile "corp" of a in a helper process whose
+// command is the helper in mode cmd.
+func execHelper(t *testing.T, a App, cmd string, env ...string) (string, error) {
+	t.Helper()
+	c := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	c.Env = append(os.Environ(), "GPX_TEST_HELPER=exec", "GPX_TEST_CMD="+cmd,
+		"GPX_TEST_CONFIG="+a.ConfigPath, "GPX_TEST_STATE="+a.StatePath)
+	c.Env = append(c.Env, env...)
+	out, err := c.Output()
+	return string(out), err
+}
+
+func TestExec_ExitCodeAndEnv(t *testing.T) {
+	a := testApp(t, `{"corp": {"GOPROXY": "https://proxy.corp"}}`)
+	// GOFLAGS was set by the previously active profile
+	out, err := execHelper(t, a, "env",
+		"GOPROXY=direct", ActiveProfileEnv+"=old", KeysEnv+"=GOFLAGS", "GOFLAGS=-v")
+
+	var ee *exec.ExitError
+	if !errors.As(err, &ee) || ee.ExitCode() != 3 {
+		t.Fatalf("exit = %v, want code 3; output:\n%s", err, out)
+	}
+	for _, want := range []string{
+		"GOPROXY=https://proxy.corp true",
+		"GOFLAGS= false",
+		ActiveProfileEnv + "=corp true",
+		KeysEnv + "=GOPROXY true",
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestExec_SignalReachesCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no POSIX signals")
+	}
+	a := testApp(t, `{"corp": {}}`)
+	_, err := execHelper(t, a, "term")
+
+	// gpx was replaced by the command, so the helper process itself died
+	var ee *exec.ExitError
+	if !errors.As(err, &ee) {
+		t.Fatalf("exit = %v, want killed by SIGTERM", err)
+	}
+	ws, ok := ee.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() || ws.Signal() != syscall.SIGTERM {
+		t.Fatalf("exit = %v, want killed by SIGTERM", err)
+	}
+}
+
+func TestExec_NoCommand(t *testing.T) {
+	a := testApp(t, `{"corp": {}}`)
+	if _, err := a.Exec("corp", nil); err == nil {
+		t.Fatal("Exec without a command succeeded")
+	}
+}
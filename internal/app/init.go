@@ -13,13 +13,16 @@ type InitResult struct {
 }
 
 func InitConfig(path string, force bool) (*InitResult, error) {
-	if _, err := os.Stat(path); err == nil && !force {
-		return &InitResult{Path: path, Status: "already_exists"}, nil
-	}
-
-	cfg := config.DefaultConfig()
-	if err := config.Save(path, cfg); err != nil {
+	res := &InitResult{Path: path, Status: "created"}
+	err := withLock(path, func() error {
+		if _, err := os.Stat(path); err == nil && !force {
+			res.Status = "already_exists"
+			return nil
+		}
+		return config.Save(path, config.DefaultConfig())
+	})
+	if err != nil {
 		return nil, fmt.Errorf("init config: %w", err)
 	}
-	return &InitResult{Path: path, Status: "created"}, nil
+	return res, nil
 }
@@ -0,0 +1,340 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZeraiGR/gpx/internal/config"
+	"github.com/ZeraiGR/gpx/internal/dotgpx"
+	"github.com/ZeraiGR/gpx/internal/state"
+)
+
+// LayerError reports an edit that touches what another layer defines.
+type LayerError struct {
+	What  string // e.g. `profile "corp"` or `GOPROXY in profile "corp"`
+	Layer config.Layer
+}
+
+func (e *LayerError) Error() string {
+	return fmt.Sprintf("%s is defined in the %s layer (%s); use --layer %s to edit it",
+		e.What, e.Layer.Name, e.Layer.Path, e.Layer.Name)
+}
+
+// ProjectBinding is the project config layer found from the working directory.
+type ProjectBinding struct {
+	Path    string
+	Sum     string
+	Allowed bool
+}
+
+// ProjectConfig finds the nearest project layer (.gpx/config.*) for dir.
+// Like .gpx files it is used only once allowed (see AllowProject), since
+// it comes with the repository. It returns nil if there is none.
+func (a App) ProjectConfig(dir string) (*ProjectBinding, error) {
+	path, err := config.FindProject(dir)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s: %w", path, err)
+	}
+	sum := dotgpx.Sum(b)
+	st := a.loadState()
+	return &ProjectBinding{Path: path, Sum: sum, Allowed: st.IsAllowed(path, sum)}, nil
+}
+
+// AllowTarget returns the project layer that gpx allow and gpx deny act on
+// for target, or nil if they act on a .gpx file instead: a .gpx file below
+// the project root is nearer and wins, the project layer wins otherwise.
+func (a App) AllowTarget(target string) (*ProjectBinding, error) {
+	b, err := a.ProjectConfig(target)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	if dirFileNearer(target, b.Path) {
+		return nil, nil
+	}
+	return b, nil
+}
+
+// dirFileNearer reports whether a .gpx file found from target is in a
+// directory below the root of the project layer at projectPath. Both are
+// found walking up from target, so one directory contains the other.
+func dirFileNearer(target, projectPath string) bool {
+	if filepath.Base(target) == dotgpx.FileName {
+		if st, err := os.Stat(target); err == nil && st.Mode().IsRegular() {
+			return true
+		}
+	}
+	f, err := dotgpx.Find(target)
+	if err != nil || f == "" {
+		return false
+	}
+	projectRoot := filepath.Dir(filepath.Dir(projectPath))
+	rel, err := filepath.Rel(projectRoot, filepath.Dir(f))
+	if err != nil || rel == "." {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// LoadLayers loads and merges the config layers: the system layer
+// (config.SystemDir), the team layer ($GPX_TEAM_CONFIG), the user layer
+// (a.ConfigPath) and an allowed project layer, later ones taking precedence.
+// Missing files are skipped, but the user file is required when there is no
+// other layer.
+func (a App) LoadLayers() (*config.Layered, error) {
+	l, err := a.loadLayers()
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	return l, nil
+}
+
+func (a App) loadLayers() (*config.Layered, error) {
+	var system, team, project *config.Layer
+	if p := config.FindIn(config.SystemDir); p != "" {
+		cfg, err := config.LoadLayer(p)
+		if err != nil {
+			return nil, err
+		}
+		system = &config.Layer{Name: config.LayerSystem, Path: p, Config: cfg}
+	}
+	if p := teamPath(); p != "" {
+		cfg, err := config.LoadLayer(p)
+		if err != nil {
+			return nil, err
+		}
+		team = &config.Layer{Name: config.LayerTeam, Path: p, Config: cfg}
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		b, err := a.ProjectConfig(cwd)
+		if err != nil {
+			return nil, err
+		}
+		if b != nil && b.Allowed {
+			cfg, err := config.LoadLayer(b.Path)
+			if err != nil {
+				return nil, err
+			}
+			project = &config.Layer{Name: config.LayerProject, Path: b.Path, Config: cfg}
+		}
+	}
+
+	var layers []config.Layer
+	if system != nil {
+		layers = append(layers, *system)
+	}
+	if team != nil {
+		layers = append(layers, *team)
+	}
+	_, statErr := os.Stat(a.ConfigPath)
+	if statErr == nil || (system == nil && team == nil && project == nil) {
+		cfg, err := config.LoadLayer(a.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, config.Layer{Name: config.LayerUser, Path: a.ConfigPath, Config: cfg})
+	}
+	if project != nil {
+		layers = append(layers, *project)
+	}
+	return config.Merge(layers)
+}
+
+// teamPath returns the team layer file, or "" if $GPX_TEAM_CONFIG is unset
+// or names no file.
+func teamPath() string {
+	p := os.Getenv(config.TeamEnv)
+	if p == "" {
+		return ""
+	}
+	if st, err := os.Stat(p); err != nil || !st.Mode().IsRegular() {
+		return ""
+	}
+	return p
+}
+
+// layerPath returns the file edits go to: a.Layer, or the user layer.
+func (a App) layerPath() (string, string, error) {
+	switch a.Layer {
+	case "", config.LayerUser:
+		return config.LayerUser, a.ConfigPath, nil
+	case config.LayerSystem:
+		if p := config.FindIn(config.SystemDir); p != "" {
+			return config.LayerSystem, p, nil
+		}
+		return config.LayerSystem, filepath.Join(config.SystemDir, "config.json"), nil
+	case config.LayerTeam:
+		p := os.Getenv(config.TeamEnv)
+		if p == "" {
+			return "", "", fmt.Errorf("no team layer: %s is not set", config.TeamEnv)
+		}
+		return config.LayerTeam, p, nil
+	case config.LayerProject:
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", "", fmt.Errorf("get working dir: %w", err)
+		}
+		p, err := config.FindProject(cwd)
+		if err != nil {
+			return "", "", err
+		}
+		if p == "" {
+			p = filepath.Join(cwd, config.ProjectDir, "config.json")
+		}
+		return config.LayerProject, p, nil
+	default:
+		return "", "", fmt.Errorf("unknown layer %q (expected system, team, user or project)", a.Layer)
+	}
+}
+
+// LayerEdit is an edit of one config layer.
+type LayerEdit struct {
+	*config.Editor
+	Name, Path string
+	// Merged is the view of all layers from before the edit.
+	Merged *config.Layered
+}
+
+// ownProfile reports an error unless the edited layer defines profile.
+func (le *LayerEdit) ownProfile(profile string) error {
+	if _, ok := le.Config.Profiles[profile]; ok {
+		return nil
+	}
+	if layers := le.Merged.ProfileLayers(profile); len(layers) > 0 {
+		return &LayerError{What: fmt.Sprintf("profile %q", profile), Layer: layers[len(layers)-1]}
+	}
+	return &ProfileNotFoundError{Name: profile}
+}
+
+// otherLayers returns the layers other than the edited one that define profile.
+func (le *LayerEdit) otherLayers(profile string) []config.Layer {
+	var out []config.Layer
+	for _, layer := range le.Merged.ProfileLayers(profile) {
+		if layer.Path != le.Path {
+			out = append(out, layer)
+		}
+	}
+	return out
+}
+
+// EditConfig applies fn to the layer selected by a.Layer (the user layer by
+// default) and saves it in place (see config.Edit), so comments and ordering
+// in the file survive. The edited layer must still merge cleanly with the
+// others. The file is locked for the whole edit.
+func (a App) EditConfig(fn func(le *LayerEdit) error) error {
+	name, path, err := a.layerPath()
+	if err != nil {
+		return err
+	}
+	return withLock(path, func() error { return a.editLayer(name, path, fn) })
+}
+
+func (a App) editLayer(name, path string, fn func(le *LayerEdit) error) error {
+	merged, err := a.LoadLayers()
+	if err != nil {
+		return err
+	}
+	if err := config.EnsureFile(path); err != nil {
+		return fmt.Errorf("create config %s: %w", path, err)
+	}
+
+	err = config.EditLayer(path,
+		func(e *config.Editor) error {
+			return fn(&LayerEdit{Editor: e, Name: name, Path: path, Merged: merged})
+		},
+		func(cfg *config.Config) error {
+			_, err := merged.Replace(config.Layer{Name: name, Path: path, Config: cfg})
+			return err
+		},
+		a.Rewrite)
+	if err != nil {
+		return err
+	}
+
+	if name == config.LayerProject {
+		// the user edited it through gpx, so the new content is trusted
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read config %s: %w", path, err)
+		}
+		sum := dotgpx.Sum(b)
+		if err := a.updateState(func(s state.Store) error { return s.Allow(path, sum) }); err != nil {
+			return fmt.Errorf("save state: %w", err)
+		}
+	}
+	return nil
+}
+
+// AllowProject trusts the current content of the project layer found from dir.
+func (a App) AllowProject(dir string) (*ProjectBinding, error) {
+	b, err := a.ProjectConfig(dir)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	if err := a.updateState(func(s state.Store) error { return s.Allow(b.Path, b.Sum) }); err != nil {
+		return nil, fmt.Errorf("save state: %w", err)
+	}
+	b.Allowed = true
+	return b, nil
+}
+
+// DenyProject removes the project layer found from dir from the trusted list.
+func (a App) DenyProject(dir string) (*ProjectBinding, error) {
+	b, err := a.ProjectConfig(dir)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	if err := a.updateState(func(s state.Store) error { return s.Deny(b.Path) }); err != nil {
+		return nil, fmt.Errorf("save state: %w", err)
+	}
+	b.Allowed = false
+	return b, nil
+}
+
+// OriginVar is a profile variable with the file it was read from.
+type OriginVar struct {
+	Value string
+	// Profile that defined the key; differs from the shown one only
+	// for inherited keys.
+	Profile string
+	Layer   string
+	Path    string
+}
+
+// ShowProfileOrigins returns the profile variables with the layer each one
+// comes from; with resolved, inherited keys are included.
+func (a App) ShowProfileOrigins(name string, resolved bool) (map[string]OriginVar, error) {
+	l, err := a.LoadLayers()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := l.Config.Profiles[name]; !ok {
+		return nil, &ProfileNotFoundError{Name: name}
+	}
+
+	vars := map[string]config.ResolvedVar{}
+	if resolved {
+		if vars, err = l.Config.Resolve(name); err != nil {
+			return nil, fmt.Errorf("resolve profile: %w", err)
+		}
+	} else {
+		for k, v := range l.Config.Profiles[name] {
+			vars[k] = config.ResolvedVar{Value: v, Origin: name}
+		}
+	}
+
+	out := make(map[string]OriginVar, len(vars))
+	for k, v := range vars {
+		ov := OriginVar{Value: v.Value, Profile: v.Origin}
+		if layer, ok := l.Origin(v.Origin, k); ok {
+			ov.Layer, ov.Path = layer.Name, layer.Path
+		}
+		out[k] = ov
+	}
+	return out, nil
+}
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZeraiGR/gpx/internal/config"
+)
+
+func TestLoadLayers_TeamLayer(t *testing.T) {
+	a := testApp(t, `{"corp": {"GOPROXY": "user"}}`)
+	sysDir := config.SystemDir
+	config.SystemDir = t.TempDir()
+	t.Cleanup(func() { config.SystemDir = sysDir })
+	team := filepath.Join(t.TempDir(), "team.json")
+	if err := os.WriteFile(team, []byte(`{"version": 1, "profiles": {"corp": {"GOPROXY": "team", "GOFLAGS": "team"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(config.TeamEnv, team)
+
+	l, err := a.LoadLayers()
+	if err != nil {
+		t.Fatalf("LoadLayers: %v", err)
+	}
+	if got := l.Config.Profiles["corp"]; got["GOPROXY"] != "user" || got["GOFLAGS"] != "team" {
+		t.Fatalf("corp = %v, want GOPROXY from user and GOFLAGS from team", got)
+	}
+	if layer, _ := l.Origin("corp", "GOFLAGS"); layer.Name != config.LayerTeam || layer.Path != team {
+		t.Fatalf("GOFLAGS origin = %+v, want the team layer", layer)
+	}
+
+	a.Layer = config.LayerTeam
+	if err := a.SetProfileVars("corp", []string{"GOFLAGS=-mod=mod"}); err != nil {
+		t.Fatalf("SetProfileVars on the team layer: %v", err)
+	}
+	cfg, err := config.Load(team)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := cfg.Profiles["corp"]["GOFLAGS"]; v != "-mod=mod" {
+		t.Fatalf("team GOFLAGS = %q", v)
+	}
+}
+
+func TestBackupRetention_IgnoresProjectLayer(t *testing.T) {
+	a := testApp(t, `{}`)
+	if err := os.WriteFile(a.ConfigPath, []byte(`{"version": 1, "profiles": {}, "backup": {"keep": 3}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sysDir := config.SystemDir
+	config.SystemDir = t.TempDir()
+	t.Cleanup(func() { config.SystemDir = sysDir })
+	t.Setenv(config.TeamEnv, "")
+
+	repo := t.TempDir()
+	proj := filepath.Join(repo, config.ProjectDir, "config.json")
+	if err := os.MkdirAll(filepath.Dir(proj), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(proj, []byte(`{"version": 1, "profiles": {}, "backup": {"keep": 1}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+	if _, err := a.AllowProject(repo); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := a.LoadLayers(); err != nil || len(l.Layers) != 2 {
+		t.Fatalf("project layer not loaded: %v", err)
+	}
+
+	if r := a.BackupRetention(); r.Keep != 3 {
+		t.Fatalf("Keep = %d, want the user layer's 3", r.Keep)
+	}
+}
+
+func TestAllowTarget_NearerFileWins(t *testing.T) {
+	a := testApp(t, `{}`)
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	for path, content := range map[string]string{
+		filepath.Join(root, ".gpx"):                    "outer\n",
+		filepath.Join(repo, ".gpx", "config.json"):     `{"version": 1, "profiles": {}}`,
+		filepath.Join(repo, "sub", ".gpx"):             "corp\n",
+		filepath.Join(repo, "sub", "deep", "keep"):     "",
+		filepath.Join(repo, "sibling", "keep"):         "",
+		filepath.Join(root, "repo-other", ".gpx"):      "other\n",
+		filepath.Join(root, "repo-other", "x", "keep"): "",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(repo, link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	// a directory of the repository linked to one outside it
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, ".gpx"), []byte("corp\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(repo, "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name, target string
+		project      bool
+	}{
+		{"same directory", repo, true},
+		{"nested directory", filepath.Join(repo, "sub"), false},
+		{"nested below the .gpx file", filepath.Join(repo, "sub", "deep"), false},
+		{"the .gpx file itself", filepath.Join(repo, "sub", ".gpx"), false},
+		{"sibling without a .gpx file", filepath.Join(repo, "sibling"), true},
+		{"symlinked root", link, true},
+		{"symlinked nested directory", filepath.Join(link, "sub", "deep"), false},
+		{"directory linked from outside", filepath.Join(repo, "linked"), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := a.AllowTarget(tt.target)
+			if err != nil {
+				t.Fatalf("AllowTarget: %v", err)
+			}
+			if got := b != nil; got != tt.project {
+				t.Fatalf("project layer chosen = %v, want %v", got, tt.project)
+			}
+		})
+	}
+
+	// outside the project there is no project layer at all
+	if b, err := a.AllowTarget(filepath.Join(root, "repo-other", "x")); err != nil || b != nil {
+		t.Fatalf("AllowTarget outside the project = %+v, %v; want nil", b, err)
+	}
+}
@@ -2,14 +2,15 @@ package app
 
 import (
 	"fmt"
+	"os"
 	"sort"
-
-	"github.com/ZeraiGR/gpx/internal/state"
 )
 
 type ProfileItem struct {
 	Name   string
 	Active bool
+	// Dir is set for the profile bound to the current directory by an allowed .gpx file.
+	Dir bool
 }
 
 func (a App) ListProfiles() ([]ProfileItem, error) {
@@ -18,12 +19,19 @@ func (a App) ListProfiles() ([]ProfileItem, error) {
 		return nil, err
 	}
 
-	st, _ := state.Load() // best-effort
+	st := a.loadState()
 	active := ""
 	if st != nil {
 		active = st.ActiveProfile
 	}
 
+	dirProfile := ""
+	if cwd, err := os.Getwd(); err == nil {
+		if b, err := a.DirProfile(cwd); err == nil && b != nil && b.Allowed {
+			dirProfile = b.File.Profile
+		}
+	}
+
 	names := make([]string, 0, len(cfg.Profiles))
 	for name := range cfg.Profiles {
 		names = append(names, name)
@@ -32,7 +40,7 @@ func (a App) ListProfiles() ([]ProfileItem, error) {
 
 	out := make([]ProfileItem, 0, len(names))
 	for _, n := range names {
-		out = append(out, ProfileItem{Name: n, Active: n == active})
+		out = append(out, ProfileItem{Name: n, Active: n == active, Dir: n == dirProfile})
 	}
 	return out, nil
 }
@@ -41,14 +49,28 @@ func FormatProfiles(items []ProfileItem) string {
 	if len(items) == 0 {
 		return "(no profiles)\n"
 	}
+	hasDir := false
+	for _, it := range items {
+		hasDir = hasDir || it.Dir
+	}
 	out := ""
 	for _, it := range items {
 		marker := " "
 		if it.Active {
 			marker = "*"
 		}
+		if hasDir {
+			if it.Dir {
+				marker += "@"
+			} else {
+				marker += " "
+			}
+		}
 		out += fmt.Sprintf("%s %s\n", marker, it.Name)
 	}
 	out += "\nLegend: * = active (last used/applied)\n"
+	if hasDir {
+		out += "        @ = bound to current directory (.gpx)\n"
+	}
 	return out
 }
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"time"
+
+	"github.com/ZeraiGR/gpx/internal/fsx"
+	"github.com/ZeraiGR/gpx/internal/state"
+)
+
+// lockTimeout bounds the wait for another gpx changing the same file.
+const lockTimeout = 5 * time.Second
+
+// withLock runs fn holding the lock of path (see fsx.Lock), so that the
+// read-modify-write in fn does not interleave with another gpx process.
+// For a symlink the lock is that of the file it points to (see lockTarget).
+func withLock(path string, fn func() error) error {
+	l, err := fsx.Lock(lockTarget(path), lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return fn()
+}
+
+// lockTarget returns the file whose lock guards writes to path. For a
+// symlink it is named after the file the link points to, so that gpx runs
+// writing through different links to it exclude each other, and kept in the
+// locks directory of the state dir: the target usually lives in a dotfiles
+// repository, which must not collect lock files (see fsx.Lock).
+func lockTarget(path string) string {
+	target, err := fsx.Resolve(path)
+	if err != nil || target == path {
+		return path
+	}
+	if abs, err := filepath.Abs(target); err == nil {
+		target = abs
+	}
+	def, err := state.DefaultPath()
+	if err != nil {
+		return path
+	}
+	sum := sha256.Sum256([]byte(target))
+	return filepath.Join(filepath.Dir(def), "locks", filepath.Base(target)+"-"+hex.EncodeToString(sum[:8]))
+}
+
+// withLockUnless is withLock, except that a dry run, which writes nothing,
+// does not take (or create) the lock.
+func withLockUnless(dryRun bool, path string, fn func() error) error {
+	if dryRun {
+		return fn()
+	}
+	return withLock(path, fn)
+}
+
+// updateState runs fn on the state file under its lock.
+func (a App) updateState(fn func(state.Store) error) error {
+	store, err := a.StateStore()
+	if err != nil {
+		return err
+	}
+	return withLock(store.Path, func() error { return fn(store) })
+}
+
+// setActiveProfile records the active profile. It is best-effort and
+// should not break the main command.
+func (a App) setActiveProfile(name string) {
+	_ = a.updateState(func(s state.Store) error { return s.SetActiveProfile(name) })
+}
+
+// setBlockProfile records the profile applied in a named GPX block of an rc
+// file; best-effort like setActiveProfile.
+func (a App) setBlockProfile(rcPath, block, profile string) {
+	_ = a.updateState(func(s state.Store) error { return s.SetBlockProfile(rcKey(rcPath), block, profile) })
+}
+
+// rcKey is the key of an rc file in state.State.Blocks: its absolute path.
+func rcKey(rcPath string) string {
+	if abs, err := filepath.Abs(rcPath); err == nil {
+		return abs
+	}
+	return rcPath
+}
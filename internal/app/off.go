@@ -0,0 +1,44 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ZeraiGR/gpx/internal/config"
+	"github.com/ZeraiGR/gpx/internal/envx"
+)
+
+var ErrNoActiveProfile = errors.New("no gpx profile is active in this shell")
+
+// Off renders the lines that deactivate the profile in the current shell.
+// With a saved snapshot (see UseOptions.Save) the original values are restored
+// exactly; otherwise keys of the active profile are simply unset.
+func (a App) Off(d envx.Dialect) ([]string, error) {
+	if saved, ok := os.LookupEnv(SavedEnv); ok {
+		snap, err := envx.DecodeSnapshot(saved)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", SavedEnv, err)
+		}
+		lines, err := snap.RestoreLines(d)
+		if err != nil {
+			return nil, fmt.Errorf("render restore: %w", err)
+		}
+		return append(lines, d.Unset(SavedEnv)), nil
+	}
+
+	active := os.Getenv(ActiveProfileEnv)
+	if active == "" {
+		return nil, ErrNoActiveProfile
+	}
+
+	keys := []string{ActiveProfileEnv, KeysEnv}
+	// Without KeysEnv the config says what the profile set; it may have been
+	// removed or broken since, the markers still go.
+	var cfg *config.Config
+	if _, ok := os.LookupEnv(KeysEnv); !ok {
+		cfg, _ = a.LoadConfig()
+	}
+	keys = append(keys, activeKeys(cfg)...)
+	return envx.UnsetLinesFor(d, keys)
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ZeraiGR/gpx/internal/config"
+	"github.com/ZeraiGR/gpx/internal/goenv"
+)
+
+// Paths are the files gpx reads and writes, as resolved for this App.
+// Optional ones are "" when there is no such file.
+type Paths struct {
+	Config string
+	State  string
+	// System is the system config layer; "" if it does not exist.
+	System string
+	// Team is the team config layer; "" if there is none.
+	Team string
+	// Project is the project config layer found from the working directory.
+	Project *ProjectBinding
+	// GoEnv is the go env file; "" with GOENV=off.
+	GoEnv string
+}
+
+func (a App) Paths() (Paths, error) {
+	p := Paths{Config: a.ConfigPath, System: config.FindIn(config.SystemDir), Team: teamPath()}
+
+	store, err := a.StateStore()
+	if err != nil {
+		return Paths{}, err
+	}
+	p.State = store.Path
+	cwd, err := os.Getwd()
+	if err != nil {
+		return Paths{}, fmt.Errorf("get working dir: %w", err)
+	}
+	if p.Project, err = a.ProjectConfig(cwd); err != nil {
+		return Paths{}, err
+	}
+	p.GoEnv, _ = goenv.Path() // GOENV=off: nothing to show
+	return p, nil
+}
+
+func FormatPaths(p Paths) string {
+	orNone := func(s string) string {
+		if s == "" {
+			return "(none)"
+		}
+		return s
+	}
+	out := fmt.Sprintf("config:   %s\n", p.Config)
+	out += fmt.Sprintf("state:    %s\n", p.State)
+	out += fmt.Sprintf("system:   %s\n", orNone(p.System))
+	out += fmt.Sprintf("team:     %s\n", orNone(p.Team))
+	switch {
+	case p.Project == nil:
+		out += "project:  (none)\n"
+	case p.Project.Allowed:
+		out += fmt.Sprintf("project:  %s\n", p.Project.Path)
+	default:
+		out += fmt.Sprintf("project:  %s (not allowed; run gpx allow)\n", p.Project.Path)
+	}
+	out += fmt.Sprintf("go env:   %s\n", orNone(p.GoEnv))
+	return out
+}
@@ -2,65 +2,66 @@ package app
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/ZeraiGR/gpx/internal/config"
 	"github.com/ZeraiGR/gpx/internal/envx"
 )
 
 func (a App) AddProfile(name string) error {
-	cfg, err := a.LoadConfig()
-	if err != nil {
-		return err
-	}
-	if name == "" {
-		return fmt.Errorf("profile name is empty")
-	}
-	if _, exists := cfg.Profiles[name]; exists {
-		return fmt.Errorf("profile %q already exists", name)
-	}
-	cfg.Profiles[name] = map[string]string{}
-	if err := config.Save(a.ConfigPath, cfg); err != nil {
-		return fmt.Errorf("save config: %w", err)
-	}
-	return nil
+	return a.EditConfig(func(le *LayerEdit) error {
+		if name == "" {
+			return fmt.Errorf("profile name is empty")
+		}
+		if _, exists := le.Merged.Config.Profiles[name]; exists {
+			return fmt.Errorf("profile %q already exists", name)
+		}
+		le.AddProfile(name)
+		return nil
+	})
 }
 
+// RemoveProfile removes the profile from the edited layer. It stays
+// available if another layer defines it too.
 func (a App) RemoveProfile(name string) error {
-	cfg, err := a.LoadConfig()
-	if err != nil {
-		return err
-	}
-	if _, exists := cfg.Profiles[name]; !exists {
-		return &ProfileNotFoundError{Name: name}
-	}
-	delete(cfg.Profiles, name)
-	if err := config.Save(a.ConfigPath, cfg); err != nil {
-		return fmt.Errorf("save config: %w", err)
-	}
-	return nil
+	return a.EditConfig(func(le *LayerEdit) error {
+		if _, exists := le.Merged.Config.Profiles[name]; !exists {
+			return &ProfileNotFoundError{Name: name}
+		}
+		if err := le.ownProfile(name); err != nil {
+			return err
+		}
+		if len(le.otherLayers(name)) == 0 {
+			if children := le.Merged.Config.Children(name); len(children) > 0 {
+				return fmt.Errorf("profile %q is extended by %v", name, children)
+			}
+		}
+		le.Editor.RemoveProfile(name)
+		return nil
+	})
 }
 
 func (a App) RenameProfile(oldName, newName string) error {
-	cfg, err := a.LoadConfig()
-	if err != nil {
-		return err
-	}
-	if _, ok := cfg.Profiles[oldName]; !ok {
-		return &ProfileNotFoundError{Name: oldName}
-	}
-	if newName == "" {
-		return fmt.Errorf("new profile name is empty")
-	}
-	if _, exists := cfg.Profiles[newName]; exists {
-		return fmt.Errorf("profile %q already exists", newName)
-	}
-	cfg.Profiles[newName] = cfg.Profiles[oldName]
-	delete(cfg.Profiles, oldName)
-
-	if err := config.Save(a.ConfigPath, cfg); err != nil {
-		return fmt.Errorf("save config: %w", err)
-	}
-	return nil
+	return a.EditConfig(func(le *LayerEdit) error {
+		if _, ok := le.Merged.Config.Profiles[oldName]; !ok {
+			return &ProfileNotFoundError{Name: oldName}
+		}
+		if newName == "" {
+			return fmt.Errorf("new profile name is empty")
+		}
+		if _, exists := le.Merged.Config.Profiles[newName]; exists {
+			return fmt.Errorf("profile %q already exists", newName)
+		}
+		// a rename must not leave parts of the profile behind in other layers
+		if err := le.ownProfile(oldName); err != nil {
+			return err
+		}
+		if others := le.otherLayers(oldName); len(others) > 0 {
+			return &LayerError{What: fmt.Sprintf("profile %q", oldName), Layer: others[0]}
+		}
+		le.Editor.RenameProfile(oldName, newName)
+		return nil
+	})
 }
 
 func (a App) ShowProfile(name string) (map[string]string, error) {
@@ -75,49 +76,103 @@ func (a App) ShowProfile(name string) (map[string]string, error) {
 	return p, nil
 }
 
-func (a App) SetProfileVars(profile string, tokens []string) error {
+// ShowResolvedProfile returns the profile variables with inheritance applied,
+// along with the profile each key came from.
+func (a App) ShowResolvedProfile(name string) (map[string]config.ResolvedVar, error) {
 	cfg, err := a.LoadConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	p, ok := cfg.Profiles[profile]
-	if !ok {
-		return &ProfileNotFoundError{Name: profile}
+	if _, ok := cfg.Profiles[name]; !ok {
+		return nil, &ProfileNotFoundError{Name: name}
 	}
-
-	vars, err := envx.ParseAssignments(tokens)
+	vars, err := cfg.Resolve(name)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("resolve profile: %w", err)
 	}
+	return vars, nil
+}
 
-	for k, v := range vars {
-		p[k] = v
-	}
+// SetProfileExtends replaces the parent list of a profile in the edited
+// layer. An empty list removes inheritance.
+func (a App) SetProfileExtends(name string, parents []string) error {
+	return a.EditConfig(func(le *LayerEdit) error {
+		cfg := le.Merged.Config
+		if _, ok := cfg.Profiles[name]; !ok {
+			return &ProfileNotFoundError{Name: name}
+		}
+		for _, p := range parents {
+			if _, ok := cfg.Profiles[p]; !ok {
+				return &ProfileNotFoundError{Name: p}
+			}
+		}
+		if _, own := le.Config.Extends[name]; len(parents) == 0 && !own {
+			for _, layer := range le.Merged.Layers {
+				if _, ok := layer.Config.Extends[name]; ok && layer.Path != le.Path {
+					return &LayerError{What: fmt.Sprintf("extends of profile %q", name), Layer: layer}
+				}
+			}
+		}
+		le.SetExtends(name, parents)
+		return nil
+	})
+}
 
-	if err := config.Save(a.ConfigPath, cfg); err != nil {
-		return fmt.Errorf("save config: %w", err)
-	}
-	return nil
+// SetProfileVars sets variables in the edited layer. A profile defined only
+// in other layers gets an entry there that overrides those keys.
+func (a App) SetProfileVars(profile string, tokens []string) error {
+	return a.EditConfig(func(le *LayerEdit) error {
+		if _, ok := le.Merged.Config.Profiles[profile]; !ok {
+			return &ProfileNotFoundError{Name: profile}
+		}
+
+		vars, err := envx.ParseAssignments(tokens)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := le.Config.Profiles[profile]; !ok {
+			le.AddProfile(profile)
+		}
+		// in command-line order, so new keys are appended as typed
+		for _, k := range assignmentKeys(tokens) {
+			le.SetVar(profile, k, vars[k])
+		}
+		return nil
+	})
 }
 
+// UnsetProfileVars removes variables from the edited layer. Keys set by
+// another layer are reported, since removing them there is a separate edit.
 func (a App) UnsetProfileVars(profile string, keys []string) error {
-	cfg, err := a.LoadConfig()
-	if err != nil {
-		return err
-	}
-	p, ok := cfg.Profiles[profile]
-	if !ok {
-		return &ProfileNotFoundError{Name: profile}
-	}
-	for _, k := range keys {
-		k = envx.NormalizeKey(k)
-		if err := envx.ValidateKey(k); err != nil {
-			return err
+	return a.EditConfig(func(le *LayerEdit) error {
+		if _, ok := le.Merged.Config.Profiles[profile]; !ok {
+			return &ProfileNotFoundError{Name: profile}
 		}
-		delete(p, k)
-	}
-	if err := config.Save(a.ConfigPath, cfg); err != nil {
-		return fmt.Errorf("save config: %w", err)
-	}
-	return nil
+		for _, k := range keys {
+			k = envx.NormalizeKey(k)
+			if err := envx.ValidateKey(k); err != nil {
+				return err
+			}
+			if _, own := le.Config.Profiles[profile][k]; !own {
+				if layer, ok := le.Merged.Origin(profile, k); ok {
+					return &LayerError{What: fmt.Sprintf("%s in profile %q", k, profile), Layer: layer}
+				}
+				continue
+			}
+			le.UnsetVar(profile, k)
+		}
+		return nil
+	})
+}
+
+// assignmentKeys returns the keys of KEY=VALUE tokens in order, normalized
+// like envx.ParseAssignments does.
+func assignmentKeys(tokens []string) []string {
+	keys := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		k, _, _ := strings.Cut(t, "=")
+		keys = append(keys, strings.ToUpper(k))
+	}
+	return keys
 }
@@ -3,6 +3,8 @@ package app
 import (
 	"fmt"
 	"sort"
+
+	"github.com/ZeraiGR/gpx/internal/config"
 )
 
 func FormatProfileVars(name string, vars map[string]string) string {
@@ -21,3 +23,48 @@ func FormatProfileVars(name string, vars map[string]string) string {
 	}
 	return out
 }
+
+// FormatResolvedProfileVars prints resolved variables with their origin profile.
+func FormatResolvedProfileVars(name string, vars map[string]config.ResolvedVar) string {
+	if len(vars) == 0 {
+		return "(empty)\n"
+	}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := fmt.Sprintf("%s (resolved):\n", name)
+	for _, k := range keys {
+		v := vars[k]
+		out += fmt.Sprintf("  %s=%q  (from %s)\n", k, v.Value, v.Origin)
+	}
+	return out
+}
+
+// FormatProfileOrigins prints variables with the config file each came from.
+func FormatProfileOrigins(name string, resolved bool, vars map[string]OriginVar) string {
+	if len(vars) == 0 {
+		return "(empty)\n"
+	}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := fmt.Sprintf("%s:\n", name)
+	if resolved {
+		out = fmt.Sprintf("%s (resolved):\n", name)
+	}
+	for _, k := range keys {
+		v := vars[k]
+		from := fmt.Sprintf("%s layer, %s", v.Layer, v.Path)
+		if resolved {
+			from = fmt.Sprintf("%s, %s", v.Profile, from)
+		}
+		out += fmt.Sprintf("  %s=%q  (from %s)\n", k, v.Value, from)
+	}
+	return out
+}
@@ -0,0 +1,19 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/ZeraiGR/gpx/internal/config"
+)
+
+// resolveProfile returns the profile variables with inheritance applied.
+func resolveProfile(cfg *config.Config, name string) (map[string]string, error) {
+	if _, ok := cfg.Profiles[name]; !ok {
+		return nil, &ProfileNotFoundError{Name: name}
+	}
+	vars, err := cfg.ResolveVars(name)
+	if err != nil {
+		return nil, fmt.Errorf("resolve profile: %w", err)
+	}
+	return vars, nil
+}
@@ -6,12 +6,12 @@ import (
 	"github.com/ZeraiGR/gpx/internal/envx"
 )
 
-func (a App) SetVars(tokens []string) ([]string, error) {
+func (a App) SetVars(tokens []string, d envx.Dialect) ([]string, error) {
 	vars, err := envx.ParseAssignments(tokens)
 	if err != nil {
 		return nil, err
 	}
-	lines, err := vars.ExportLines()
+	lines, err := vars.ExportLinesFor(d)
 	if err != nil {
 		return nil, fmt.Errorf("render exports: %w", err)
 	}
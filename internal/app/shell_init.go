@@ -0,0 +1,32 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/ZeraiGR/gpx/internal/shell"
+)
+
+// InstallShellInit writes the line loading `gpx shell-init` into its own
+// managed block of the rc file, next to (not inside) the `gpx apply` block.
+func InstallShellInit(sh string, hook bool, rcPath string, opts shell.ApplyOptions) (*ApplyReport, error) {
+	lines, err := shell.InitLines(sh, hook)
+	if err != nil {
+		return nil, err
+	}
+	opts.Markers = shell.InitMarkers
+	var res *shell.ApplyResult
+	err = withLockUnless(opts.DryRun, rcPath, func() (err error) {
+		res, err = shell.ApplyToRC(rcPath, lines, opts)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("apply to rc: %w", err)
+	}
+	return &ApplyReport{
+		RCPath:      res.RCPath,
+		Target:      res.Target,
+		BackupPath:  res.BackupPath,
+		WouldChange: res.WouldChange,
+		NewContent:  res.NewContent,
+	}, nil
+}
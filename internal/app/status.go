@@ -4,15 +4,53 @@ import (
 	"fmt"
 	"os"
 	"sort"
+
+	"github.com/ZeraiGR/gpx/internal/envx"
+	"github.com/ZeraiGR/gpx/internal/goenv"
+	"github.com/ZeraiGR/gpx/internal/shell"
+)
+
+// Value sources reported in StatusRow.Source.
+const (
+	SourceEnv     = "env"
+	SourceGoEnv   = "goenv"
+	SourceDefault = "default"
 )
 
 type StatusRow struct {
 	Key   string
 	Value string
 	Set   bool
+
+	// GoKey is set for variables the go command reads; only those have
+	// go env file and default values.
+	GoKey    bool
+	GoEnv    string
+	HasGoEnv bool
+	Default  string
+
+	// RC is the value in the managed block of the rc file; RCErr is set
+	// instead when the rc file could not be read.
+	RC    string
+	HasRC bool
+	RCErr string
+
+	// Source says which value the go command actually uses: SourceEnv,
+	// SourceGoEnv or SourceDefault; "" for non-Go keys that are not set.
+	Source    string
+	Effective string
+}
+
+type StatusOptions struct {
+	// RCPath is the rc file whose managed block is inspected; empty skips it.
+	RCPath string
+	// Dialect is used to read the rc block; nil means POSIX.
+	Dialect envx.Dialect
+	// GoEnvPath is the go env file; empty skips it.
+	GoEnvPath string
 }
 
-func (a App) Status() ([]StatusRow, error) {
+func (a App) Status(opts StatusOptions) ([]StatusRow, error) {
 	cfg, err := a.LoadConfig()
 	if err != nil {
 		return nil, err
@@ -31,25 +69,191 @@ func (a App) Status() ([]StatusRow, error) {
 	}
 	sort.Strings(keys)
 
+	goEnv := map[string]string{}
+	if opts.GoEnvPath != "" {
+		if goEnv, err = goenv.Read(opts.GoEnvPath); err != nil {
+			return nil, err
+		}
+	}
+	// An unreadable rc file is reported per row, the rest is still useful.
+	rc, rcErr := readRCBlock(opts.RCPath, opts.Dialect)
+
+	// lookup returns the value the go command uses for a variable, for the
+	// defaults derived from other variables (see goenv.Default).
+	var lookup func(string) string
+	lookup = func(k string) string {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+		if v := goEnv[k]; v != "" {
+			return v
+		}
+		return goenv.Default(k, lookup)
+	}
+
 	rows := make([]StatusRow, 0, len(keys))
 	for _, k := range keys {
 		v, ok := os.LookupEnv(k)
-		rows = append(rows, StatusRow{Key: k, Value: v, Set: ok})
+		r := StatusRow{Key: k, Value: v, Set: ok, GoKey: goenv.IsKnownKey(k)}
+		r.RC, r.HasRC = rc[k]
+		if rcErr != nil {
+			r.RCErr = rcErr.Error()
+		}
+		if r.GoKey {
+			r.GoEnv, r.HasGoEnv = goEnv[k]
+			r.Default = goenv.Default(k, lookup)
+		}
+
+		// The go command ignores empty values and falls through to the next source.
+		switch {
+		case !r.GoKey && r.Set:
+			r.Source, r.Effective = SourceEnv, r.Value
+		case !r.GoKey:
+		case r.Value != "":
+			r.Source, r.Effective = SourceEnv, r.Value
+		case r.GoEnv != "":
+			r.Source, r.Effective = SourceGoEnv, r.GoEnv
+		default:
+			r.Source, r.Effective = SourceDefault, r.Default
+		}
+		rows = append(rows, r)
 	}
 	return rows, nil
 }
 
+// readRCBlock returns the variables exported in the managed blocks of
+// rcPath, later blocks overriding earlier ones as when the file is sourced.
+// A missing file or block yields an empty map.
+func readRCBlock(rcPath string, d envx.Dialect) (map[string]string, error) {
+	out := map[string]string{}
+	blocks, err := rcBlocks(rcPath, d)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		for k, v := range b.Vars {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+// RCBlock is a GPX block of an rc file.
+type RCBlock struct {
+	Name               string // "" for the unnamed block
+	Profile            string // from the GPX_PROFILE marker; "" if missing
+	BeginLine, EndLine int
+	Vars               map[string]string
+}
+
+// RCBlocks lists the GPX blocks of rcPath (see shell.Markers.Blocks) in
+// file order. The profile of a named block is the one recorded when it was
+// applied. A missing file yields none.
+func (a App) RCBlocks(rcPath string, d envx.Dialect) ([]RCBlock, error) {
+	blocks, err := rcBlocks(rcPath, d)
+	if err != nil {
+		return nil, err
+	}
+	st := a.loadState()
+	for i, b := range blocks {
+		if b.Name != "" && b.Profile == "" {
+			blocks[i].Profile = st.BlockProfile(rcKey(rcPath), b.Name)
+		}
+	}
+	return blocks, nil
+}
+
+// rcBlocks parses the GPX blocks of rcPath; only the unnamed block (or one
+// written by an older gpx) carries its profile.
+func rcBlocks(rcPath string, d envx.Dialect) ([]RCBlock, error) {
+	if rcPath == "" {
+		return nil, nil
+	}
+	if d == nil {
+		d = envx.POSIX
+	}
+	b, err := os.ReadFile(rcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read rc %s: %w", rcPath, err)
+	}
+	var out []RCBlock
+	for _, blk := range shell.ProfileMarkers.Blocks(string(b)) {
+		rb := RCBlock{Name: blk.Name, BeginLine: blk.BeginLine, EndLine: blk.EndLine, Vars: map[string]string{}}
+		for _, ln := range blk.Lines {
+			if k, v, ok := d.ParseExport(ln); ok {
+				if k == ActiveProfileEnv {
+					rb.Profile = v
+					continue
+				}
+				if k == KeysEnv {
+					continue
+				}
+				rb.Vars[k] = v
+			}
+		}
+		out = append(out, rb)
+	}
+	return out, nil
+}
+
+func FormatRCBlocks(blocks []RCBlock) string {
+	if len(blocks) == 0 {
+		return "GPX blocks: (none)\n"
+	}
+	out := "GPX blocks:\n"
+	for _, b := range blocks {
+		name := b.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		profile := b.Profile
+		if profile == "" {
+			profile = "(unknown)"
+		}
+		out += fmt.Sprintf("  %-12s profile %-12s lines %d-%d\n", name, profile, b.BeginLine, b.EndLine)
+	}
+	return out
+}
+
 func FormatStatus(rows []StatusRow) string {
 	if len(rows) == 0 {
 		return "(no variables found in profiles)"
 	}
 	out := ""
 	for _, r := range rows {
-		if !r.Set {
+		if r.Source == "" {
 			out += fmt.Sprintf("%s: (not set)\n", r.Key)
 		} else {
-			out += fmt.Sprintf("%s: %q\n", r.Key, r.Value)
+			out += fmt.Sprintf("%s: %q (from %s)\n", r.Key, r.Effective, r.Source)
+		}
+		out += statusLine(r.Source == SourceEnv, "env", r.Value, r.Set)
+		if r.GoKey {
+			out += statusLine(r.Source == SourceGoEnv, "go env", r.GoEnv, r.HasGoEnv)
+		}
+		if r.RCErr != "" {
+			out += fmt.Sprintf("    %-9s (error: %s)\n", "rc block", r.RCErr)
+		} else {
+			out += statusLine(false, "rc block", r.RC, r.HasRC)
+		}
+		if r.GoKey {
+			out += statusLine(r.Source == SourceDefault, "default", r.Default, true)
 		}
 	}
+	out += "\nLegend: * = value the go command uses\n"
 	return out
 }
+
+func statusLine(wins bool, label, value string, has bool) string {
+	marker := " "
+	if wins {
+		marker = "*"
+	}
+	v := "(not set)"
+	if has {
+		v = fmt.Sprintf("%q", value)
+	}
+	return fmt.Sprintf("  %s %-9s %s\n", marker, label, v)
+}
@@ -0,0 +1,29 @@
+package app
+
+import "testing"
+
+func TestStatus_UnreadableRC(t *testing.T) {
+	a := testApp(t, `{"corp": {"GOPROXY": "https://proxy.corp"}}`)
+	// A directory cannot be read as an rc file.
+	rows, err := a.Status(StatusOptions{RCPath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Key != "GOPROXY" || rows[0].RCErr == "" {
+		t.Fatalf("rows = %+v, want GOPROXY with an rc error", rows)
+	}
+}
+
+func TestStatus_DerivedDefaults(t *testing.T) {
+	a := testApp(t, `{"corp": {"GONOSUMDB": ""}}`)
+	t.Setenv("GOPRIVATE", "corp.local/*")
+	t.Setenv("GONOSUMDB", "")
+	rows, err := a.Status(StatusOptions{})
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	r := rows[0]
+	if r.Source != SourceDefault || r.Effective != "corp.local/*" {
+		t.Fatalf("GONOSUMDB = %q from %s, want the GOPRIVATE value from default", r.Effective, r.Source)
+	}
+}
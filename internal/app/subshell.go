@@ -0,0 +1,38 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrProfileActive = errors.New("profile is already active in this shell")
+
+type ShellOptions struct {
+	// Shell is the program to start; defaults to $SHELL, then /bin/sh.
+	Shell string
+	// Force allows starting a subshell for the profile that is already active.
+	Force bool
+}
+
+// Shell starts an interactive subshell with the profile applied
+// and returns its exit code. Leaving the subshell drops the profile.
+func (a App) Shell(name string, opts ShellOptions) (int, error) {
+	if os.Getenv(ActiveProfileEnv) == name && !opts.Force {
+		return 0, fmt.Errorf("%w: %s (use --force to nest)", ErrProfileActive, name)
+	}
+
+	sh := opts.Shell
+	if sh == "" {
+		sh = os.Getenv("SHELL")
+	}
+	if sh == "" {
+		sh = "/bin/sh"
+	}
+
+	env, err := a.ProfileEnviron(name)
+	if err != nil {
+		return 0, err
+	}
+	return run([]string{sh}, env)
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeShell writes an sh script that runs body and returns its path.
+func fakeShell(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("no sh")
+	}
+	path := filepath.Join(t.TempDir(), "fakesh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestShell_RefusesNesting(t *testing.T) {
+	a := testApp(t, `{"corp": {"GOPROXY": "https://proxy.corp"}}`)
+	t.Setenv(ActiveProfileEnv, "corp")
+
+	_, err := a.Shell("corp", ShellOptions{Shell: fakeShell(t, "exit 0")})
+	if !errors.Is(err, ErrProfileActive) {
+		t.Fatalf("Shell error = %v, want ErrProfileActive", err)
+	}
+
+	code, err := a.Shell("corp", ShellOptions{Shell: fakeShell(t, "exit 4"), Force: true})
+	if err != nil || code != 4 {
+		t.Fatalf("Shell --force = %d, %v; want 4", code, err)
+	}
+}
+
+func TestShell_Environment(t *testing.T) {
+	a := testApp(t, `{"corp": {"GOPROXY": "https://proxy.corp"}}`)
+	// GOFLAGS was set by the profile active in this shell
+	t.Setenv(ActiveProfileEnv, "old")
+	t.Setenv(KeysEnv, "GOFLAGS")
+	t.Setenv("GOFLAGS", "-v")
+	out := filepath.Join(t.TempDir(), "env")
+	t.Setenv("GPX_TEST_OUT", out)
+
+	sh := fakeShell(t, `echo "GOPROXY=$GOPROXY GOFLAGS=${GOFLAGS-unset} `+
+		ActiveProfileEnv+`=$`+ActiveProfileEnv+` `+KeysEnv+`=$`+KeysEnv+`" > "$GPX_TEST_OUT"`)
+	if code, err := a.Shell("corp", ShellOptions{Shell: sh}); err != nil || code != 0 {
+		t.Fatalf("Shell = %d, %v", code, err)
+	}
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "GOPROXY=https://proxy.corp GOFLAGS=unset " + ActiveProfileEnv + "=corp " + KeysEnv + "=GOPROXY"
+	if got := strings.TrimSpace(string(b)); got != want {
+		t.Fatalf("subshell env:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestShell_SignalExitCode(t *testing.T) {
+	a := testApp(t, `{"corp": {}}`)
+	code, err := a.Shell("corp", ShellOptions{Shell: fakeShell(t, "kill -TERM $$")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 128+15 {
+		t.Fatalf("code = %d, want %d", code, 128+15)
+	}
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ZeraiGR/gpx/internal/envx"
+	"github.com/ZeraiGR/gpx/internal/shell"
+	"github.com/ZeraiGR/gpx/internal/state"
+)
+
+type UnapplyReport struct {
+	ApplyReport
+	// Found is false when the rc file has no such block; nothing is written then.
+	Found bool
+	// Profile is the profile the block applied, if its marker was readable.
+	Profile string
+}
+
+// UnapplyFromRC removes the block written by ApplyProfileToRC (the one of
+// opts.Markers) and forgets the block's profile: as the active one for the
+// unnamed block, from the recorded block profiles for a named one.
+func (a App) UnapplyFromRC(rcPath string, d envx.Dialect, opts shell.ApplyOptions) (*UnapplyReport, error) {
+	rep := &UnapplyReport{ApplyReport: ApplyReport{RCPath: rcPath}}
+	block := blockName(opts)
+	err := withLockUnless(opts.DryRun, rcPath, func() error {
+		b, err := os.ReadFile(rcPath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("read rc %s: %w", rcPath, err)
+		}
+		m := opts.BlockMarkers()
+		if err := m.Check(string(b)); err != nil {
+			return fmt.Errorf("rc %s: %w", rcPath, err)
+		}
+		lines, found := m.ReadBlock(string(b))
+		if !found {
+			return nil
+		}
+		rep.Found = true
+		rep.Profile = exportedProfile(lines, d)
+		if block != "" && rep.Profile == "" {
+			rep.Profile = a.loadState().BlockProfile(rcKey(rcPath), block)
+		}
+
+		res, err := shell.UpdateFile(rcPath, func(old string) (string, error) {
+			s, _ := m.Remove(old)
+			return s, nil
+		}, opts)
+		if err != nil {
+			return err
+		}
+		rep.Target, rep.BackupPath = res.Target, res.BackupPath
+		rep.WouldChange, rep.NewContent = res.WouldChange, res.NewContent
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unapply from rc: %w", err)
+	}
+
+	if rep.Found && !opts.DryRun {
+		// best-effort, like recording it in apply
+		_ = a.updateState(func(s state.Store) error {
+			if block != "" {
+				return s.ClearBlockProfile(rcKey(rcPath), block)
+			}
+			return s.ClearActiveProfile(rep.Profile)
+		})
+	}
+	return rep, nil
+}
+
+// exportedProfile returns the GPX_PROFILE marker among the lines of a block.
+func exportedProfile(lines []string, d envx.Dialect) string {
+	profile := ""
+	for _, ln := range lines {
+		if k, v, ok := d.ParseExport(ln); ok && k == ActiveProfileEnv {
+			profile = v
+		}
+	}
+	return profile
+}
+
+// blockProfile returns the profile of the unnamed GPX block of rc content,
+// or "" if there is none.
+func blockProfile(rc string, d envx.Dialect) string {
+	lines, found := shell.ProfileMarkers.ReadBlock(rc)
+	if !found {
+		return ""
+	}
+	return exportedProfile(lines, d)
+}
@@ -2,29 +2,161 @@ package app
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
+	"github.com/ZeraiGR/gpx/internal/config"
 	"github.com/ZeraiGR/gpx/internal/envx"
-	"github.com/ZeraiGR/gpx/internal/state"
 )
 
-func (a App) UseProfile(name string) ([]string, error) {
+const (
+	// ActiveProfileEnv is exported alongside profile variables so that
+	// the next `gpx use` in the same shell knows what it is switching from.
+	ActiveProfileEnv = "GPX_PROFILE"
+	// KeysEnv lists, comma-separated, the keys gpx set along with
+	// ActiveProfileEnv, so they can be unset even after the profile changed.
+	KeysEnv = "GPX_KEYS"
+	// SavedEnv holds the encoded snapshot of values that existed
+	// before the first `gpx use --save` (see envx.Snapshot).
+	SavedEnv = "GPX_SAVED"
+)
+
+type UseOptions struct {
+	// Dialect selects the output shell syntax; nil means POSIX.
+	Dialect envx.Dialect
+	// Save records original values of touched keys so `gpx off` can restore them.
+	// Once a snapshot exists in the shell it is always extended, regardless of Save.
+	Save bool
+}
+
+// UseProfile renders the lines that switch the current shell to the profile:
+// unset lines for keys the previously active profile set and the target
+// does not define, followed by export lines.
+func (a App) UseProfile(name string, opts UseOptions) ([]string, error) {
 	cfg, err := a.LoadConfig()
 	if err != nil {
 		return nil, err
 	}
-	p, ok := cfg.Profiles[name]
-	if !ok {
-		return nil, &ProfileNotFoundError{Name: name}
+	p, err := resolveProfile(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	d := opts.Dialect
+	if d == nil {
+		d = envx.POSIX
+	}
+	stale := staleKeys(cfg, p)
+
+	var lines []string
+	if len(stale) > 0 {
+		unset, err := envx.UnsetLinesFor(d, stale)
+		if err != nil {
+			return nil, fmt.Errorf("render unsets: %w", err)
+		}
+		lines = append(lines, unset...)
 	}
 
-	vars := envx.Vars(p)
-	lines, err := vars.ExportLines()
+	exports, err := profileExportLines(d, name, p)
 	if err != nil {
-		return nil, fmt.Errorf("render exports: %w", err)
+		return nil, err
+	}
+	lines = append(lines, exports...)
+
+	saved, hasSaved := os.LookupEnv(SavedEnv)
+	if opts.Save || hasSaved {
+		snap := envx.Snapshot{}
+		if hasSaved {
+			if snap, err = envx.DecodeSnapshot(saved); err != nil {
+				return nil, fmt.Errorf("%s: %w", SavedEnv, err)
+			}
+		}
+		touched := append(stale, ActiveProfileEnv, KeysEnv)
+		for k := range p {
+			touched = append(touched, k)
+		}
+		snap.Capture(touched)
+
+		enc, err := snap.Encode()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, d.Export(SavedEnv, enc))
 	}
 
 	// Mark as active (best-effort; should not break the main command).
-	_ = state.SetActiveProfile(name)
+	a.setActiveProfile(name)
+
+	return lines, nil
+}
 
+// profileExportLines renders export lines for resolved profile vars
+// plus the ActiveProfileEnv and KeysEnv markers.
+func profileExportLines(d envx.Dialect, name string, p map[string]string) ([]string, error) {
+	vars := envx.Vars{}
+	for k, v := range p {
+		vars[k] = v
+	}
+	markProfile(vars, name)
+
+	lines, err := vars.ExportLinesFor(d)
+	if err != nil {
+		return nil, fmt.Errorf("render exports: %w", err)
+	}
 	return lines, nil
 }
+
+// markProfile adds the ActiveProfileEnv and KeysEnv markers to the
+// variables of the named profile.
+func markProfile(vars envx.Vars, name string) {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		if k != ActiveProfileEnv && k != KeysEnv {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	vars[ActiveProfileEnv] = name
+	vars[KeysEnv] = strings.Join(keys, ",")
+}
+
+// activeKeys returns the keys gpx set in this shell: the KeysEnv list, or,
+// in shells activated before that marker existed, the keys of the
+// ActiveProfileEnv profile as cfg defines it now. Unknown or broken
+// profiles yield none, and list entries that are not valid keys are skipped.
+func activeKeys(cfg *config.Config) []string {
+	if list, ok := os.LookupEnv(KeysEnv); ok {
+		var keys []string
+		for _, k := range strings.Split(list, ",") {
+			if envx.ValidateKey(k) == nil {
+				keys = append(keys, k)
+			}
+		}
+		return keys
+	}
+	prev := os.Getenv(ActiveProfileEnv)
+	if prev == "" || cfg == nil {
+		return nil
+	}
+	if _, ok := cfg.Profiles[prev]; !ok {
+		return nil
+	}
+	pv, err := cfg.ResolveVars(prev)
+	if err != nil {
+		return nil
+	}
+	return sortedKeys(pv)
+}
+
+// staleKeys returns keys set for the profile active in this shell (see
+// activeKeys) that are missing from the target profile.
+func staleKeys(cfg *config.Config, target map[string]string) []string {
+	var keys []string
+	for _, k := range activeKeys(cfg) {
+		if _, ok := target[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
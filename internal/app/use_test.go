@@ -0,0 +1,112 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ZeraiGR/gpx/internal/envx"
+)
+
+// testApp returns an App on a config file with the given JSON profiles and
+// its own state file.
+func testApp(t *testing.T, profiles string) App {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(cfg, []byte(`{"version": 1, "profiles": `+profiles+`}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return App{ConfigPath: cfg, StatePath: filepath.Join(dir, "state.json")}
+}
+
+func TestUseProfile_UnsetsKeysGpxSet(t *testing.T) {
+	a := testApp(t, `{"old": {"GOPROXY": "x"}, "new": {"GOFLAGS": "-v"}}`)
+	// "old" had GOPRIVATE when it was activated; the config no longer does.
+	t.Setenv(ActiveProfileEnv, "old")
+	t.Setenv(KeysEnv, "GOPRIVATE,GOPROXY")
+
+	lines, err := a.UseProfile("new", UseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"unset GOPRIVATE", "unset GOPROXY", "export GPX_KEYS='GOFLAGS'"} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("missing %q in:\n%s", want, strings.Join(lines, "\n"))
+		}
+	}
+}
+
+func TestOff_UsesKeysList(t *testing.T) {
+	a := testApp(t, `{"old": {"GOPROXY": "x"}}`)
+	t.Setenv(ActiveProfileEnv, "old")
+	t.Setenv(KeysEnv, "GOPRIVATE")
+
+	lines, err := a.Off(envx.POSIX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(lines, "unset GOPRIVATE") || slices.Contains(lines, "unset GOPROXY") {
+		t.Fatalf("Off should unset what gpx set, got:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestUseProfile_SkipsInvalidKeysInList(t *testing.T) {
+	a := testApp(t, `{"new": {"GOFLAGS": "-v"}}`)
+	t.Setenv(ActiveProfileEnv, "old")
+	t.Setenv(KeysEnv, "GOPROXY,bad key,$(rm -rf ~),,GOPRIVATE")
+
+	lines, err := a.UseProfile("new", UseOptions{})
+	if err != nil {
+		t.Fatalf("UseProfile with a broken %s: %v", KeysEnv, err)
+	}
+	for _, want := range []string{"unset GOPRIVATE", "unset GOPROXY"} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("missing %q in:\n%s", want, strings.Join(lines, "\n"))
+		}
+	}
+	for _, l := range lines {
+		if strings.Contains(l, "rm -rf") || strings.Contains(l, "bad key") {
+			t.Errorf("invalid key passed through: %q", l)
+		}
+	}
+
+	if _, err := a.Off(envx.POSIX); err != nil {
+		t.Fatalf("Off with a broken %s: %v", KeysEnv, err)
+	}
+}
+
+func TestUseProfile_UnsetsPreviousProfileKeys(t *testing.T) {
+	a := testApp(t, `{"old": {"GOPROXY": "x", "GOFLAGS": "-v"}, "new": {"GOFLAGS": "-mod=mod"}}`)
+	// a shell activated before GPX_KEYS existed: the keys come from the config
+	t.Setenv(ActiveProfileEnv, "old")
+	t.Setenv(KeysEnv, "") // restored after the test
+	os.Unsetenv(KeysEnv)
+
+	lines, err := a.UseProfile("new", UseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(lines, "unset GOPROXY") || slices.Contains(lines, "unset GOFLAGS") {
+		t.Fatalf("want only GOPROXY unset, got:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestUseProfile_IgnoresUnknownPreviousProfile(t *testing.T) {
+	a := testApp(t, `{"new": {"GOFLAGS": "-v"}}`)
+	t.Setenv(ActiveProfileEnv, "gone")
+	t.Setenv(KeysEnv, "") // restored after the test
+	os.Unsetenv(KeysEnv)
+
+	lines, err := a.UseProfile("new", UseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range lines {
+		if strings.HasPrefix(l, "unset ") {
+			t.Fatalf("unexpected %q for an unknown previous profile", l)
+		}
+	}
+}
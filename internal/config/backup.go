@@ -0,0 +1,79 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupPolicy is the retention policy for the rc file backups made with
+// --backup. Backups beyond either limit are removed after a new one is made;
+// the newest backup is always kept.
+type BackupPolicy struct {
+	// Keep is how many backups of each file to keep; 0 means no limit.
+	Keep int `json:"keep,omitempty"`
+	// MaxAge removes older backups: a Go duration ("720h") or days ("30d");
+	// "" means no limit.
+	MaxAge string `json:"max_age,omitempty"`
+}
+
+// MaxAgeDuration parses MaxAge; "" is 0 (no limit).
+func (p BackupPolicy) MaxAgeDuration() (time.Duration, error) {
+	if p.MaxAge == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(p.MaxAge, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("bad max_age %q", p.MaxAge)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(p.MaxAge)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("bad max_age %q: use a duration like 720h or days like 30d", p.MaxAge)
+	}
+	return d, nil
+}
+
+func (p *BackupPolicy) validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Keep < 0 {
+		return fmt.Errorf("backup: keep must not be negative, got %d", p.Keep)
+	}
+	if _, err := p.MaxAgeDuration(); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts keep as a number or as numeric text: the YAML and
+// TOML readers keep scalars as text.
+func (p *BackupPolicy) UnmarshalJSON(data []byte) error {
+	type policy BackupPolicy
+	aux := struct {
+		Keep json.RawMessage `json:"keep"`
+		*policy
+	}{policy: (*policy)(p)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.Keep) == 0 || string(aux.Keep) == "null" {
+		return nil
+	}
+	text := string(aux.Keep)
+	var s string
+	if json.Unmarshal(aux.Keep, &s) == nil {
+		text = s
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil {
+		return fmt.Errorf("backup: bad keep %s", aux.Keep)
+	}
+	p.Keep = n
+	return nil
+}
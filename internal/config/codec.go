@@ -0,0 +1,160 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Codec reads and writes a config file format.
+type Codec interface {
+	Name() string
+	Ext() string
+	Marshal(cfg *Config) ([]byte, error)
+	Unmarshal(b []byte, cfg *Config) error
+
+	// tree parses b into a generic document (maps, lists, scalars),
+	// which migrations work on before it is decoded into a Config.
+	tree(b []byte) (any, error)
+}
+
+var (
+	JSON Codec = jsonCodec{}
+	YAML Codec = yamlCodec{}
+	TOML Codec = tomlCodec{}
+)
+
+// CodecByName maps a format name (json, yaml, yml, toml) to its codec.
+func CodecByName(name string) (Codec, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return JSON, nil
+	case "yaml", "yml":
+		return YAML, nil
+	case "toml":
+		return TOML, nil
+	default:
+		return nil, fmt.Errorf("unknown config format %q (expected json, yaml or toml)", name)
+	}
+}
+
+// CodecFor picks the codec for a config file: by extension first,
+// then by sniffing content; JSON when neither says anything.
+func CodecFor(path string, content []byte) Codec {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON
+	case ".yaml", ".yml":
+		return YAML
+	case ".toml":
+		return TOML
+	}
+	return sniff(content)
+}
+
+func sniff(b []byte) Codec {
+	for _, ln := range strings.Split(string(b), "\n") {
+		ln = strings.TrimSpace(ln)
+		switch {
+		case ln == "" || strings.HasPrefix(ln, "#"):
+			continue
+		case strings.HasPrefix(ln, "{"):
+			return JSON
+		case strings.HasPrefix(ln, "["):
+			return TOML
+		case strings.HasPrefix(ln, "---"):
+			return YAML
+		}
+		// "key = value" or "key: value": whichever separator comes first
+		// outside of quotes, so TOML values with colons (URLs) stay TOML.
+		switch sep := firstSeparator(ln); sep {
+		case '=':
+			return TOML
+		case ':':
+			return YAML
+		}
+		break
+	}
+	return JSON
+}
+
+// firstSeparator returns the first '=' or ':' of ln outside of quoted
+// keys and values, or 0.
+func firstSeparator(ln string) byte {
+	for i := 0; i < len(ln); i++ {
+		switch ln[i] {
+		case '"', '\'':
+			end := quotedEnd(ln[i:])
+			if end < 0 {
+				return 0
+			}
+			i += end
+		case '=', ':':
+			return ln[i]
+		}
+	}
+	return 0
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+func (jsonCodec) Ext() string  { return ".json" }
+
+func (jsonCodec) Marshal(cfg *Config) ([]byte, error) {
+	return json.MarshalIndent(cfg, "", "  ")
+}
+
+func (c jsonCodec) Unmarshal(b []byte, cfg *Config) error {
+	return unmarshal(c, b, cfg)
+}
+
+func (jsonCodec) tree(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the top-level value")
+	}
+	return v, nil
+}
+
+// utf8BOM is the byte order mark some editors put at the start of a file.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// unmarshal parses b with c and decodes it into cfg. A leading UTF-8 byte
+// order mark is skipped for every codec.
+func unmarshal(c Codec, b []byte, cfg *Config) error {
+	tree, err := c.tree(bytes.TrimPrefix(b, utf8BOM))
+	if err != nil {
+		return err
+	}
+	return fromTree(tree, cfg)
+}
+
+// fromTree decodes a generic document (maps, lists, strings, nil) produced by
+// Codec.tree through encoding/json, so that field mapping and
+// type checks are the same for every format.
+func fromTree(tree any, cfg *Config) error {
+	if doc, ok := tree.(map[string]any); ok {
+		// YAML and TOML readers keep scalars as text
+		v, err := docVersion(doc)
+		if err != nil {
+			return err
+		}
+		if _, ok := doc["version"]; ok {
+			doc["version"] = v
+		}
+	}
+	b, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	return dec.Decode(cfg)
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sampleConfig() *Config {
+	return &Config{
+		Profiles: map[string]map[string]string{
+			"corp": {
+				"GOPROXY":   "https://proxy.corp.local,direct",
+				"GOPRIVATE": "github.com/mycorp/*",
+				"GOFLAGS":   `-tags="a b" # not a comment`,
+			},
+			"corp-ci": {"GONOSUMDB": ""},
+			"empty":   {},
+		},
+		Extends: map[string][]string{"corp-ci": {"corp"}},
+		Backup:  &BackupPolicy{Keep: 5, MaxAge: "30d"},
+	}
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	for _, c := range []Codec{JSON, YAML, TOML} {
+		want := sampleConfig()
+		b, err := c.Marshal(want)
+		if err != nil {
+			t.Fatalf("%s: Marshal error: %v", c.Name(), err)
+		}
+		var got Config
+		if err := c.Unmarshal(b, &got); err != nil {
+			t.Fatalf("%s: Unmarshal error: %v\n%s", c.Name(), err, b)
+		}
+		if !reflect.DeepEqual(&got, want) {
+			t.Fatalf("%s: round trip got %+v, want %+v\n%s", c.Name(), got, want, b)
+		}
+	}
+}
+
+func TestYAML_HandWritten(t *testing.T) {
+	src := `# gpx config
+profiles:
+  corp:
+    GOPROXY: https://proxy.corp.local,direct   # corporate proxy
+    GOPRIVATE: 'github.com/mycorp/*'
+    GONOSUMDB: ""
+  corp-ci:
+    GOFLAGS: -mod=readonly
+extends:
+  corp-ci:
+    - corp
+backup:
+  keep: 3
+`
+	var cfg Config
+	if err := YAML.Unmarshal([]byte(src), &cfg); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	want := Config{
+		Profiles: map[string]map[string]string{
+			"corp": {
+				"GOPROXY":   "https://proxy.corp.local,direct",
+				"GOPRIVATE": "github.com/mycorp/*",
+				"GONOSUMDB": "",
+			},
+			"corp-ci": {"GOFLAGS": "-mod=readonly"},
+		},
+		Extends: map[string][]string{"corp-ci": {"corp"}},
+		Backup:  &BackupPolicy{Keep: 3},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("got %+v, want %+v", cfg, want)
+	}
+}
+
+func TestTOML_HandWritten(t *testing.T) {
+	src := `# gpx config
+[profiles.corp]
+GOPROXY = "https://proxy.corp.local,direct" # corporate proxy
+GOPRIVATE = 'github.com/mycorp/*'
+
+[profiles."corp-ci"]
+GOFLAGS = "-mod=readonly"
+
+[extends]
+corp-ci = [
+  "corp",
+]
+`
+	var cfg Config
+	if err := TOML.Unmarshal([]byte(src), &cfg); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	want := Config{
+		Profiles: map[string]map[string]string{
+			"corp": {
+				"GOPROXY":   "https://proxy.corp.local,direct",
+				"GOPRIVATE": "github.com/mycorp/*",
+			},
+			"corp-ci": {"GOFLAGS": "-mod=readonly"},
+		},
+		Extends: map[string][]string{"corp-ci": {"corp"}},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("got %+v, want %+v", cfg, want)
+	}
+}
+
+func TestCodecFor_Sniff(t *testing.T) {
+	tests := []struct {
+		content string
+		want    Codec
+	}{
+		{`{"profiles": {}}`, JSON},
+		{"# c\nprofiles:\n  a: {}\n", YAML},
+		{"[profiles.a]\n", TOML},
+		{"version = 1\nurl = \"https://x\"\n", TOML},
+		{"goproxy = \"https://proxy\"\n", TOML},
+		{"\"a:b\" = 1\n", TOML},
+		{"url: \"a=b\"\n", YAML},
+		{"", JSON},
+	}
+	for _, tt := range tests {
+		if got := CodecFor("config", []byte(tt.content)); got != tt.want {
+			t.Fatalf("CodecFor(%q) = %s, want %s", tt.content, got.Name(), tt.want.Name())
+		}
+	}
+}
+
+func TestCodecs_ControlCharacters(t *testing.T) {
+	val := "a\x01b\x1b\x7f\tc\"d\\e\u00e9"
+	want := &Config{Profiles: map[string]map[string]string{"p": {"GOFLAGS": val}}}
+	for _, c := range []Codec{JSON, YAML, TOML} {
+		b, err := c.Marshal(want)
+		if err != nil {
+			t.Fatalf("%s: Marshal error: %v", c.Name(), err)
+		}
+		var got Config
+		if err := c.Unmarshal(b, &got); err != nil {
+			t.Fatalf("%s: Unmarshal error: %v\n%s", c.Name(), err, b)
+		}
+		if !reflect.DeepEqual(&got, want) {
+			t.Fatalf("%s: round trip got %q, want %q\n%s", c.Name(), got.Profiles["p"]["GOFLAGS"], val, b)
+		}
+	}
+	b, _ := TOML.Marshal(want)
+	if !strings.Contains(string(b), `"a\u0001b\u001B\u007F\tc\"d\\eé"`) {
+		t.Fatalf("TOML string not in TOML escapes:\n%s", b)
+	}
+}
+
+func TestYAML_Escapes(t *testing.T) {
+	src := `profiles:
+  p:
+    A: "\/\e\N\_\L\P\ \	\x41\u00e9\U0001F600"
+`
+	var cfg Config
+	if err := YAML.Unmarshal([]byte(src), &cfg); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	want := "/\x1b\u0085\u00a0\u2028\u2029 \tA\u00e9\U0001F600"
+	if got := cfg.Profiles["p"]["A"]; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if err := YAML.Unmarshal([]byte("profiles:\n  p:\n    A: \"\\q\"\n"), &cfg); err == nil {
+		t.Fatal("expected an error for an unknown escape")
+	}
+}
+
+func TestTOML_NestedInlineValues(t *testing.T) {
+	src := `[profiles.a]
+GOPROXY = "direct"
+
+[profiles.b]
+
+[profiles.c]
+
+[extends]
+x = { y = ["a", "b"] }
+`
+	tree, err := parseTOML(src)
+	if err != nil {
+		t.Fatalf("parseTOML error: %v", err)
+	}
+	want := map[string]any{"y": []any{"a", "b"}}
+	if got := tree["extends"].(map[string]any)["x"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("x = %#v, want %#v", got, want)
+	}
+
+	var cfg Config
+	if err := TOML.Unmarshal([]byte("extends = { c = [\"a\",\"b\"] }\n[profiles.a]\n[profiles.b]\n[profiles.c]\n"), &cfg); err != nil {
+		t.Fatalf("inline table with an array: %v", err)
+	}
+	if got := cfg.Extends["c"]; !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("extends c = %v", got)
+	}
+}
+
+func TestTOML_TableDefinedTwice(t *testing.T) {
+	for _, src := range []string{
+		"[profiles.a]\nA = \"1\"\n\n[profiles.a]\nB = \"2\"\n",
+		"[profiles]\na = { A = \"1\" }\n\n[profiles.a]\nB = \"2\"\n",
+	} {
+		_, err := parseTOML(src)
+		if err == nil || !strings.Contains(err.Error(), "line 4") {
+			t.Errorf("parseTOML(%q) error = %v, want one naming line 4", src, err)
+		}
+	}
+	// a parent table may come after its subtables
+	if _, err := parseTOML("[profiles.a]\nA = \"1\"\n[profiles]\n"); err != nil {
+		t.Errorf("implicit parent table: %v", err)
+	}
+}
+
+func TestCodecs_ByteOrderMark(t *testing.T) {
+	srcs := map[Codec]string{
+		JSON: `{"version": 1, "profiles": {"a": {"GOPROXY": "direct"}}}`,
+		YAML: "version: 1\nprofiles:\n  a:\n    GOPROXY: direct\n",
+		TOML: "version = 1\n[profiles.a]\nGOPROXY = \"direct\"\n",
+	}
+	dir := t.TempDir()
+	for c, src := range srcs {
+		b := append([]byte("\xef\xbb\xbf"), src...)
+		var cfg Config
+		if err := c.Unmarshal(b, &cfg); err != nil || cfg.Profiles["a"]["GOPROXY"] != "direct" {
+			t.Errorf("%s: Unmarshal with a BOM = %+v, %v", c.Name(), cfg.Profiles, err)
+		}
+		// by content, without the extension
+		path := filepath.Join(dir, "config-"+c.Name())
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if loaded, err := Load(path); err != nil || loaded.Profiles["a"]["GOPROXY"] != "direct" {
+			t.Errorf("%s: Load with a BOM = %+v, %v", c.Name(), loaded, err)
+		}
+	}
+}
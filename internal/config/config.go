@@ -1,63 +1,160 @@
 package config
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/ZeraiGR/gpx/internal/fsx"
 )
 
 type Config struct {
+	// Version is the schema version of the file (see CurrentVersion).
+	Version  int                          `json:"version"`
 	Profiles map[string]map[string]string `json:"profiles"`
+	// Extends maps a profile name to its parent profiles, merged in order.
+	Extends map[string][]string `json:"extends,omitempty"`
+	// Backup limits how many rc file backups are kept.
+	Backup *BackupPolicy `json:"backup,omitempty"`
 }
 
-// DefaultPath returns ~/.config/gpx/config.json (на macOS/Linux), windows doesn't supported now
-func DefaultPath() (string, error) {
+// candidateNames are the config file names DefaultPath looks for, in order.
+var candidateNames = []string{"config.json", "config.yaml", "config.yml", "config.toml"}
+
+// PathEnv overrides the user config file location.
+const PathEnv = "GPX_CONFIG"
+
+// Dir returns the user config directory: $XDG_CONFIG_HOME/gpx,
+// or ~/.config/gpx when XDG_CONFIG_HOME is unset.
+func Dir() (string, error) {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "gpx"), nil
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("get home dir: %w", err)
 	}
-	return filepath.Join(home, ".config", "gpx", "config.json"), nil
+	return filepath.Join(home, ".config", "gpx"), nil
+}
+
+// DefaultPath returns $GPX_CONFIG if set, otherwise the first existing
+// config.{json,yaml,yml,toml} in Dir (на macOS/Linux); config.json if none
+// does. windows doesn't supported now
+func DefaultPath() (string, error) {
+	if p := os.Getenv(PathEnv); p != "" {
+		return p, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	if p := FindIn(dir); p != "" {
+		return p, nil
+	}
+	return filepath.Join(dir, candidateNames[0]), nil
+}
+
+// FindIn returns the first existing config file in dir (see candidateNames),
+// or "" if there is none.
+func FindIn(dir string) string {
+	for _, name := range candidateNames {
+		p := filepath.Join(dir, name)
+		if st, err := os.Stat(p); err == nil && st.Mode().IsRegular() {
+			return p
+		}
+	}
+	return ""
 }
 
+// Load reads a config file in any supported format (see CodecFor)
+// and upgrades it to CurrentVersion in memory (see migrations).
 func Load(path string) (*Config, error) {
+	cfg, err := LoadLayer(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := Validate(cfg); err != nil {
+		return nil, fmt.Errorf("validate config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// LoadLayer is like Load, but allows references to profiles that are
+// defined in other layers (see Merge).
+func LoadLayer(path string) (*Config, error) {
+	f, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.cfg, nil
+}
+
+// loaded is a config file as read from disk.
+type loaded struct {
+	cfg   *Config
+	src   []byte
+	codec Codec
+	from  int         // version in the file
+	steps []migration // applied by Load
+}
+
+func load(path string) (*loaded, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read config %s: %w", path, err)
 	}
+	b = bytes.TrimPrefix(b, utf8BOM) // see unmarshal
+	codec := CodecFor(path, b)
+	tree, err := codec.tree(b)
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s (%s): %w", path, codec.Name(), err)
+	}
+	from, steps, err := migrate(tree)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
 	var cfg Config
-	if err := json.Unmarshal(b, &cfg); err != nil {
-		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	if err := fromTree(tree, &cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s (%s): %w", path, codec.Name(), err)
 	}
-	if err := Validate(&cfg); err != nil {
+	if err := ValidateLayer(&cfg); err != nil {
 		return nil, fmt.Errorf("validate config %s: %w", path, err)
 	}
 	if cfg.Profiles == nil {
 		cfg.Profiles = map[string]map[string]string{}
 	}
-	return &cfg, nil
+	return &loaded{cfg: &cfg, src: b, codec: codec, from: from, steps: steps}, nil
 }
 
+// Save writes the config in the format of the existing file,
+// or by the path extension for new files.
 func Save(path string, cfg *Config) error {
+	existing, _ := os.ReadFile(path) // for content sniffing only
+	return SaveAs(path, cfg, CodecFor(path, existing))
+}
+
+// SaveAs writes the config with the given codec.
+func SaveAs(path string, cfg *Config, codec Codec) error {
 	if cfg == nil {
 		return fmt.Errorf("save config: cfg is nil")
 	}
 
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, DirPerm); err != nil {
-		return fmt.Errorf("create config dir %s: %w", dir, err)
-	}
-
-	b, err := json.MarshalIndent(cfg, "", "  ")
+	b, err := codec.Marshal(cfg)
 	if err != nil {
 		return fmt.Errorf("marshal config: %w", err)
 	}
+	return writeFile(path, b)
+}
 
-	tmp := path + ".tmp"
-	if err := os.WriteFile(tmp, b, FilePerm); err != nil {
-		return fmt.Errorf("write temp config %s: %w", tmp, err)
+// writeFile atomically replaces the config file with b.
+func writeFile(path string, b []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, DirPerm); err != nil {
+		return fmt.Errorf("create config dir %s: %w", dir, err)
 	}
-	if err := os.Rename(tmp, path); err != nil {
+
+	if err := fsx.WriteFile(path, b, FilePerm); err != nil {
 		return fmt.Errorf("replace config %s: %w", path, err)
 	}
 	return nil
@@ -65,6 +162,7 @@ func Save(path string, cfg *Config) error {
 
 func DefaultConfig() *Config {
 	return &Config{
+		Version: CurrentVersion,
 		Profiles: map[string]map[string]string{
 			"public": {
 				"GOPROXY":     "https://proxy.golang.org,direct",
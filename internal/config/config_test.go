@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDefaultPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(PathEnv, "")
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	if got, _ := DefaultPath(); got != filepath.Join(home, ".config", "gpx", "config.json") {
+		t.Errorf("without XDG_CONFIG_HOME: %s", got)
+	}
+	// relative values are ignored, as the XDG spec says
+	t.Setenv("XDG_CONFIG_HOME", "rel")
+	if got, _ := DefaultPath(); got != filepath.Join(home, ".config", "gpx", "config.json") {
+		t.Errorf("relative XDG_CONFIG_HOME: %s", got)
+	}
+
+	xdg := filepath.Join(home, "xdg")
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	yaml := filepath.Join(xdg, "gpx", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(yaml), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(yaml, []byte("version: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := DefaultPath(); got != yaml {
+		t.Errorf("with XDG_CONFIG_HOME: %s, want %s", got, yaml)
+	}
+
+	t.Setenv(PathEnv, "/custom/gpx.toml")
+	if got, _ := DefaultPath(); got != "/custom/gpx.toml" {
+		t.Errorf("with %s: %s", PathEnv, got)
+	}
+}
+
+func TestBackupPolicy_MaxAge(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"36h", 36 * time.Hour},
+		{"30d", 30 * 24 * time.Hour},
+	} {
+		got, err := BackupPolicy{MaxAge: tt.in}.MaxAgeDuration()
+		if err != nil || got != tt.want {
+			t.Errorf("MaxAgeDuration(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, p := range []BackupPolicy{{Keep: -1}, {MaxAge: "a month"}, {MaxAge: "-1d"}} {
+		cfg := &Config{Version: CurrentVersion, Profiles: map[string]map[string]string{}, Backup: &p}
+		if err := Validate(cfg); err == nil {
+			t.Errorf("Validate accepted backup policy %+v", p)
+		}
+	}
+}
+
+func TestBackupPolicy_UnmarshalKeep(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want BackupPolicy
+	}{
+		{`{"keep": 5, "max_age": "30d"}`, BackupPolicy{Keep: 5, MaxAge: "30d"}},
+		{`{"keep": "5"}`, BackupPolicy{Keep: 5}},
+		{`{"keep": null}`, BackupPolicy{}},
+		{`{}`, BackupPolicy{}},
+	} {
+		var got BackupPolicy
+		if err := json.Unmarshal([]byte(tt.in), &got); err != nil || got != tt.want {
+			t.Errorf("Unmarshal(%s) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{`{"keep": "five"}`, `{"keep": 1.5}`, `{"keep": true}`} {
+		var p BackupPolicy
+		if err := json.Unmarshal([]byte(in), &p); err == nil {
+			t.Errorf("Unmarshal(%s) accepted %+v", in, p)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// errUnsupported is returned by a patcher when the document layout is beyond
+// what it can edit in place; Edit then needs to rewrite the whole file.
+var errUnsupported = errors.New("layout not supported for in-place edits")
+
+// ErrRewrite is returned by Edit when the edit cannot be made in place and
+// saving it would re-encode the whole file, dropping comments and layout.
+var ErrRewrite = errors.New("cannot edit the config in place; saving would rewrite the whole file and drop its comments and layout")
+
+// patcher edits the source text of a config file. Every method gets the
+// current text and returns the new one; the semantic change is applied
+// to Editor.Config separately.
+type patcher interface {
+	setVar(src []byte, profile, key, value string) ([]byte, error)
+	unsetVar(src []byte, profile, key string) ([]byte, error)
+	addProfile(src []byte, name string) ([]byte, error)
+	removeProfile(src []byte, name string) ([]byte, error)
+	// renameProfile renames the profile and its extends entry, if any.
+	// References in other profiles' parent lists are updated via setExtends.
+	renameProfile(src []byte, oldName, newName string) ([]byte, error)
+	// setVersion sets the top-level version, adding it near the top if missing.
+	setVersion(src []byte, version int) ([]byte, error)
+	// setExtends replaces the parent list; empty parents remove the entry.
+	setExtends(src []byte, name string, parents []string) ([]byte, error)
+}
+
+// Editor applies edits to a loaded config and, where the format allows,
+// to its source text, so hand-written ordering, blank lines and comments
+// survive CLI edits.
+type Editor struct {
+	Config *Config
+
+	codec Codec
+	src   []byte // patched text; nil once in-place editing gave up
+}
+
+// Edit loads the config at path, lets fn modify it through the Editor and
+// writes it back. The patched text is used only if it parses back to exactly
+// the edited config; otherwise Edit fails with ErrRewrite and the file is
+// left alone.
+func Edit(path string, fn func(e *Editor) error) error {
+	return EditLayer(path, fn, Validate, false)
+}
+
+// EditLayer is like Edit for one layer of several: the edited layer is
+// checked with ValidateLayer and then passed to check, which typically
+// validates it merged with the other layers. With rewrite, an edit that
+// cannot be made in place re-encodes the whole file instead of failing.
+func EditLayer(path string, fn func(e *Editor) error, check func(cfg *Config) error, rewrite bool) error {
+	f, err := load(path)
+	if err != nil {
+		return err
+	}
+	e := newEditor(f)
+	if err := fn(e); err != nil {
+		return err
+	}
+	if err := check(e.Config); err != nil {
+		return err
+	}
+	b, err := e.encode(rewrite)
+	if err != nil {
+		if errors.Is(err, ErrRewrite) {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return err
+	}
+	return writeFile(path, b)
+}
+
+// newEditor starts editing a loaded file. If Load migrated it, the new
+// version is patched in, unless a step changed the structure.
+func newEditor(f *loaded) *Editor {
+	e := &Editor{Config: f.cfg, codec: f.codec, src: f.src}
+	if _, ok := e.codec.(interface{ patcher() patcher }); !ok {
+		e.src = nil
+	}
+	for _, s := range f.steps {
+		if s.rewrite {
+			e.src = nil
+		}
+	}
+	if len(f.steps) > 0 {
+		e.patch(func(p patcher, src []byte) ([]byte, error) { return p.setVersion(src, CurrentVersion) })
+	}
+	return e
+}
+
+// encode returns the file content: the patched text if it parses back to
+// exactly the edited config, otherwise the re-encoded config with rewrite
+// and ErrRewrite without.
+func (e *Editor) encode(rewrite bool) ([]byte, error) {
+	if err := ValidateLayer(e.Config); err != nil {
+		return nil, err
+	}
+	if e.src != nil {
+		var got Config
+		if err := e.codec.Unmarshal(e.src, &got); err == nil && ValidateLayer(&got) == nil && equalConfig(&got, e.Config) {
+			return e.src, nil
+		}
+	}
+	if !rewrite {
+		return nil, ErrRewrite
+	}
+	b, err := e.codec.Marshal(e.Config)
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	return b, nil
+}
+
+func (e *Editor) patch(fn func(p patcher, src []byte) ([]byte, error)) {
+	if e.src == nil {
+		return
+	}
+	p := e.codec.(interface{ patcher() patcher }).patcher()
+	out, err := fn(p, e.src)
+	if err != nil {
+		e.src = nil
+		return
+	}
+	e.src = out
+}
+
+// SetVar sets a variable in an existing profile.
+func (e *Editor) SetVar(profile, key, value string) {
+	if old, ok := e.Config.Profiles[profile][key]; ok && old == value {
+		return
+	}
+	e.Config.Profiles[profile][key] = value
+	e.patch(func(p patcher, src []byte) ([]byte, error) { return p.setVar(src, profile, key, value) })
+}
+
+// UnsetVar removes a variable from an existing profile; missing keys are ignored.
+func (e *Editor) UnsetVar(profile, key string) {
+	if _, ok := e.Config.Profiles[profile][key]; !ok {
+		return
+	}
+	delete(e.Config.Profiles[profile], key)
+	e.patch(func(p patcher, src []byte) ([]byte, error) { return p.unsetVar(src, profile, key) })
+}
+
+// AddProfile adds an empty profile.
+func (e *Editor) AddProfile(name string) {
+	e.Config.Profiles[name] = map[string]string{}
+	e.patch(func(p patcher, src []byte) ([]byte, error) { return p.addProfile(src, name) })
+}
+
+// RemoveProfile removes a profile together with its extends entry.
+func (e *Editor) RemoveProfile(name string) {
+	delete(e.Config.Profiles, name)
+	e.patch(func(p patcher, src []byte) ([]byte, error) { return p.removeProfile(src, name) })
+	if _, ok := e.Config.Extends[name]; ok {
+		e.SetExtends(name, nil)
+	}
+}
+
+// RenameProfile renames a profile, its extends entry and every reference
+// to it in other profiles' parent lists.
+func (e *Editor) RenameProfile(oldName, newName string) {
+	cfg := e.Config
+	cfg.Profiles[newName] = cfg.Profiles[oldName]
+	delete(cfg.Profiles, oldName)
+	if parents, ok := cfg.Extends[oldName]; ok {
+		cfg.Extends[newName] = parents
+		delete(cfg.Extends, oldName)
+	}
+	e.patch(func(p patcher, src []byte) ([]byte, error) { return p.renameProfile(src, oldName, newName) })
+
+	for _, child := range sortedNames(cfg.Extends) {
+		parents := cfg.Extends[child]
+		renamed := make([]string, len(parents))
+		changed := false
+		for i, p := range parents {
+			if p == oldName {
+				p, changed = newName, true
+			}
+			renamed[i] = p
+		}
+		if changed {
+			e.SetExtends(child, renamed)
+		}
+	}
+}
+
+// SetExtends replaces the parent list of a profile; empty parents remove it.
+func (e *Editor) SetExtends(name string, parents []string) {
+	if len(parents) == 0 {
+		if _, ok := e.Config.Extends[name]; !ok {
+			return
+		}
+		delete(e.Config.Extends, name)
+	} else {
+		if e.Config.Extends == nil {
+			e.Config.Extends = map[string][]string{}
+		}
+		e.Config.Extends[name] = append([]string(nil), parents...)
+	}
+	e.patch(func(p patcher, src []byte) ([]byte, error) { return p.setExtends(src, name, parents) })
+}
+
+// equalConfig compares configs; a missing extends map equals an empty one.
+func equalConfig(a, b *Config) bool {
+	if a.Version != b.Version || len(a.Profiles) != len(b.Profiles) || len(a.Extends) != len(b.Extends) {
+		return false
+	}
+	if (a.Backup == nil) != (b.Backup == nil) || a.Backup != nil && *a.Backup != *b.Backup {
+		return false
+	}
+	for name, av := range a.Profiles {
+		bv, ok := b.Profiles[name]
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if w, ok := bv[k]; !ok || w != v {
+				return false
+			}
+		}
+	}
+	for name, ap := range a.Extends {
+		bp, ok := b.Extends[name]
+		if !ok || len(ap) != len(bp) {
+			return false
+		}
+		for i := range ap {
+			if ap[i] != bp[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// commentBlockStart returns where the comment lines directly above line i
+// start; such comments are taken to describe line i.
+func commentBlockStart(lines []string, i int) int {
+	for i > 0 && strings.HasPrefix(strings.TrimSpace(lines[i-1]), "#") {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+var editFixtures = []string{"config.json", "config.yaml", "config.toml"}
+
+// copyFixture copies testdata/edit/name into a temp dir and returns its path.
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "edit", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// editScript exercises every Editor operation.
+func editScript(e *Editor) error {
+	e.SetVar("corp", "GOPROXY", "https://proxy2.corp.local,direct")
+	e.SetVar("corp", "GOFLAGS", "-mod=mod")
+	e.UnsetVar("corp", "GONOSUMDB")
+	e.SetVar("scratch", "GOWORK", "off")
+	e.UnsetVar("public", "GOTOOLCHAIN")
+	e.AddProfile("ci")
+	e.SetVar("ci", "GOFLAGS", "-mod=vendor")
+	e.RenameProfile("corp", "acme")
+	e.SetExtends("ci", []string{"acme", "public"})
+	e.RemoveProfile("scratch")
+	return nil
+}
+
+func TestEdit_NoChangesKeepsBytes(t *testing.T) {
+	for _, name := range editFixtures {
+		path := copyFixture(t, name)
+		before := readFile(t, path)
+		if err := Edit(path, func(*Editor) error { return nil }); err != nil {
+			t.Fatalf("%s: Edit error: %v", name, err)
+		}
+		if got := readFile(t, path); got != before {
+			t.Fatalf("%s: no-op edit changed the file:\n%s", name, got)
+		}
+	}
+}
+
+func TestEdit_Golden(t *testing.T) {
+	for _, name := range editFixtures {
+		t.Run(name, func(t *testing.T) {
+			path := copyFixture(t, name)
+			if err := Edit(path, editScript); err != nil {
+				t.Fatalf("Edit error: %v", err)
+			}
+			got := readFile(t, path)
+
+			golden := filepath.Join("testdata", "edit", name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if want := readFile(t, golden); got != want {
+				t.Fatalf("edited file differs from %s:\n%s", golden, got)
+			}
+
+			// the patched text must mean the same as a full rewrite
+			want := loadFixture(t, name)
+			if err := editScript(want); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load edited file: %v", err)
+			}
+			if !equalConfig(cfg, want.Config) {
+				t.Fatalf("edited config = %+v, want %+v", cfg, want.Config)
+			}
+		})
+	}
+}
+
+func TestEdit_Idempotent(t *testing.T) {
+	for _, name := range editFixtures {
+		path := copyFixture(t, name+".golden")
+		before := readFile(t, path)
+		// setting values that are already there is a no-op
+		err := Edit(path, func(e *Editor) error {
+			e.SetVar("acme", "GOFLAGS", "-mod=mod")
+			e.SetExtends("ci", []string{"acme", "public"})
+			e.UnsetVar("public", "GOTOOLCHAIN")
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: Edit error: %v", name, err)
+		}
+		if got := readFile(t, path); got != before {
+			t.Fatalf("%s: repeated edit changed the file:\n%s", name, got)
+		}
+	}
+}
+
+func TestEdit_UnsupportedLayout(t *testing.T) {
+	path := copyFixture(t, "flow.yaml")
+	before := readFile(t, path)
+	setFlags := func(e *Editor) error {
+		e.SetVar("corp", "GOFLAGS", "-mod=mod")
+		return nil
+	}
+
+	if err := Edit(path, setFlags); !errors.Is(err, ErrRewrite) {
+		t.Fatalf("Edit error = %v, want ErrRewrite", err)
+	}
+	if got := readFile(t, path); got != before {
+		t.Fatalf("refused edit changed the file:\n%s", got)
+	}
+
+	if err := EditLayer(path, setFlags, Validate, true); err != nil {
+		t.Fatalf("EditLayer with rewrite: %v", err)
+	}
+	got := readFile(t, path)
+	golden := filepath.Join("testdata", "edit", "flow.yaml.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := readFile(t, golden); got != want {
+		t.Fatalf("rewritten file differs from %s:\n%s", golden, got)
+	}
+}
+
+// loadFixture returns an Editor without source text, i.e. semantic edits only.
+func loadFixture(t *testing.T, name string) *Editor {
+	t.Helper()
+	cfg, err := Load(filepath.Join("testdata", "edit", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Editor{Config: cfg}
+}
@@ -0,0 +1,374 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func (jsonCodec) patcher() patcher { return jsonPatcher{} }
+
+// jsonNode is a JSON value with its byte span in the source.
+type jsonNode struct {
+	kind       byte // '{', '[', '"' or 0 for other scalars
+	start, end int  // [start, end)
+	str        string
+	members    []jsonMember
+	items      []*jsonNode
+}
+
+type jsonMember struct {
+	key              string
+	keyStart, keyEnd int
+	val              *jsonNode
+}
+
+func (n *jsonNode) member(key string) *jsonMember {
+	if n == nil || n.kind != '{' {
+		return nil
+	}
+	for i := range n.members {
+		if n.members[i].key == key {
+			return &n.members[i]
+		}
+	}
+	return nil
+}
+
+func (n *jsonNode) memberIndex(key string) int {
+	for i := range n.members {
+		if n.members[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+type jsonScanner struct {
+	src string
+	pos int
+}
+
+func parseJSONSpans(src string) (*jsonNode, error) {
+	s := &jsonScanner{src: src}
+	n, err := s.value()
+	if err != nil {
+		return nil, err
+	}
+	s.skipSpace()
+	if s.pos != len(src) {
+		return nil, fmt.Errorf("json: unexpected data at offset %d", s.pos)
+	}
+	return n, nil
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.src) && strings.IndexByte(" \t\r\n", s.src[s.pos]) >= 0 {
+		s.pos++
+	}
+}
+
+func (s *jsonScanner) value() (*jsonNode, error) {
+	s.skipSpace()
+	if s.pos >= len(s.src) {
+		return nil, fmt.Errorf("json: unexpected end of input")
+	}
+	n := &jsonNode{start: s.pos}
+	switch c := s.src[s.pos]; c {
+	case '{':
+		n.kind = '{'
+		s.pos++
+		s.skipSpace()
+		if s.pos < len(s.src) && s.src[s.pos] == '}' {
+			s.pos++
+			break
+		}
+		for {
+			s.skipSpace()
+			k, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+			if k.kind != '"' {
+				return nil, fmt.Errorf("json: expected key at offset %d", k.start)
+			}
+			if err := s.expect(':'); err != nil {
+				return nil, err
+			}
+			v, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+			n.members = append(n.members, jsonMember{key: k.str, keyStart: k.start, keyEnd: k.end, val: v})
+			s.skipSpace()
+			if s.pos < len(s.src) && s.src[s.pos] == ',' {
+				s.pos++
+				continue
+			}
+			if err := s.expect('}'); err != nil {
+				return nil, err
+			}
+			break
+		}
+	case '[':
+		n.kind = '['
+		s.pos++
+		s.skipSpace()
+		if s.pos < len(s.src) && s.src[s.pos] == ']' {
+			s.pos++
+			break
+		}
+		for {
+			v, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, v)
+			s.skipSpace()
+			if s.pos < len(s.src) && s.src[s.pos] == ',' {
+				s.pos++
+				continue
+			}
+			if err := s.expect(']'); err != nil {
+				return nil, err
+			}
+			break
+		}
+	case '"':
+		n.kind = '"'
+		end := quotedEnd(s.src[s.pos:])
+		if end < 0 {
+			return nil, fmt.Errorf("json: unterminated string at offset %d", s.pos)
+		}
+		if err := json.Unmarshal([]byte(s.src[s.pos:s.pos+end+1]), &n.str); err != nil {
+			return nil, fmt.Errorf("json: bad string at offset %d", s.pos)
+		}
+		s.pos += end + 1
+	default:
+		for s.pos < len(s.src) && strings.IndexByte(",}] \t\r\n", s.src[s.pos]) < 0 {
+			s.pos++
+		}
+		if s.pos == n.start {
+			return nil, fmt.Errorf("json: unexpected %q at offset %d", c, s.pos)
+		}
+	}
+	n.end = s.pos
+	return n, nil
+}
+
+func (s *jsonScanner) expect(c byte) error {
+	s.skipSpace()
+	if s.pos >= len(s.src) || s.src[s.pos] != c {
+		return fmt.Errorf("json: expected %q at offset %d", c, s.pos)
+	}
+	s.pos++
+	return nil
+}
+
+// jsonPatcher edits JSON text through byte spans, so member order and
+// whitespace outside the edited members stay as they are.
+type jsonPatcher struct{}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func jsonList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, it := range items {
+		quoted[i] = jsonString(it)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// lineIndent returns the leading whitespace of the line containing offset.
+func lineIndent(src string, offset int) string {
+	start := strings.LastIndexByte(src[:offset], '\n') + 1
+	i := start
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+		i++
+	}
+	return src[start:i]
+}
+
+func splice(src string, start, end int, repl string) string {
+	return src[:start] + repl + src[end:]
+}
+
+// insertMember appends "key": val to object n.
+func insertMember(src string, n *jsonNode, key, val string) string {
+	entry := jsonString(key) + ": " + val
+	if len(n.members) == 0 {
+		ind := lineIndent(src, n.start)
+		return splice(src, n.start+1, n.end-1, "\n"+ind+"  "+entry+"\n"+ind)
+	}
+	last := n.members[len(n.members)-1]
+	if !strings.Contains(src[n.start:last.keyStart], "\n") {
+		// single-line object
+		return splice(src, last.val.end, last.val.end, ", "+entry)
+	}
+	return splice(src, last.val.end, last.val.end, ",\n"+lineIndent(src, last.keyStart)+entry)
+}
+
+// removeMember deletes the i-th member of object n with its separator.
+func removeMember(src string, n *jsonNode, i int) string {
+	switch {
+	case len(n.members) == 1:
+		return splice(src, n.start+1, n.end-1, "")
+	case i < len(n.members)-1:
+		return splice(src, n.members[i].keyStart, n.members[i+1].keyStart, "")
+	default:
+		return splice(src, n.members[i-1].val.end, n.members[i].val.end, "")
+	}
+}
+
+// load parses src and returns it with the root object and its "profiles" object.
+func (jsonPatcher) load(src []byte) (string, *jsonNode, *jsonNode, error) {
+	s := string(src)
+	root, err := parseJSONSpans(s)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	m := root.member("profiles")
+	if m == nil || m.val.kind != '{' {
+		return "", nil, nil, errUnsupported
+	}
+	return s, root, m.val, nil
+}
+
+func (p jsonPatcher) profile(src []byte, name string) (string, *jsonNode, error) {
+	s, _, profiles, err := p.load(src)
+	if err != nil {
+		return "", nil, err
+	}
+	m := profiles.member(name)
+	if m == nil || m.val.kind != '{' {
+		return "", nil, errUnsupported
+	}
+	return s, m.val, nil
+}
+
+func (p jsonPatcher) setVar(src []byte, profile, key, value string) ([]byte, error) {
+	s, prof, err := p.profile(src, profile)
+	if err != nil {
+		return nil, err
+	}
+	if m := prof.member(key); m != nil {
+		return []byte(splice(s, m.val.start, m.val.end, jsonString(value))), nil
+	}
+	return []byte(insertMember(s, prof, key, jsonString(value))), nil
+}
+
+func (p jsonPatcher) unsetVar(src []byte, profile, key string) ([]byte, error) {
+	s, prof, err := p.profile(src, profile)
+	if err != nil {
+		return nil, err
+	}
+	i := prof.memberIndex(key)
+	if i < 0 {
+		return nil, errUnsupported
+	}
+	return []byte(removeMember(s, prof, i)), nil
+}
+
+func (p jsonPatcher) addProfile(src []byte, name string) ([]byte, error) {
+	s, _, profiles, err := p.load(src)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(insertMember(s, profiles, name, "{}")), nil
+}
+
+func (p jsonPatcher) removeProfile(src []byte, name string) ([]byte, error) {
+	s, _, profiles, err := p.load(src)
+	if err != nil {
+		return nil, err
+	}
+	i := profiles.memberIndex(name)
+	if i < 0 {
+		return nil, errUnsupported
+	}
+	return []byte(removeMember(s, profiles, i)), nil
+}
+
+func (p jsonPatcher) renameProfile(src []byte, oldName, newName string) ([]byte, error) {
+	s, root, profiles, err := p.load(src)
+	if err != nil {
+		return nil, err
+	}
+	m := profiles.member(oldName)
+	if m == nil {
+		return nil, errUnsupported
+	}
+	// splice the later span first so earlier offsets stay valid
+	var spans [][2]int
+	if ext := root.member("extends"); ext != nil {
+		if em := ext.val.member(oldName); em != nil {
+			spans = append(spans, [2]int{em.keyStart, em.keyEnd})
+		}
+	}
+	spans = append(spans, [2]int{m.keyStart, m.keyEnd})
+	if len(spans) == 2 && spans[0][0] < spans[1][0] {
+		spans[0], spans[1] = spans[1], spans[0]
+	}
+	for _, sp := range spans {
+		s = splice(s, sp[0], sp[1], jsonString(newName))
+	}
+	return []byte(s), nil
+}
+
+func (p jsonPatcher) setExtends(src []byte, name string, parents []string) ([]byte, error) {
+	s, root, _, err := p.load(src)
+	if err != nil {
+		return nil, err
+	}
+	ext := root.member("extends")
+	if ext == nil {
+		if len(parents) == 0 {
+			return src, nil
+		}
+		return []byte(insertMember(s, root, "extends", "{"+jsonString(name)+": "+jsonList(parents)+"}")), nil
+	}
+	if ext.val.kind != '{' {
+		return nil, errUnsupported
+	}
+	i := ext.val.memberIndex(name)
+	switch {
+	case len(parents) == 0 && i < 0:
+		return src, nil
+	case len(parents) == 0:
+		return []byte(removeMember(s, ext.val, i)), nil
+	case i < 0:
+		return []byte(insertMember(s, ext.val, name, jsonList(parents))), nil
+	default:
+		v := ext.val.members[i].val
+		return []byte(splice(s, v.start, v.end, jsonList(parents))), nil
+	}
+}
+
+func (jsonPatcher) setVersion(src []byte, version int) ([]byte, error) {
+	s := string(src)
+	root, err := parseJSONSpans(s)
+	if err != nil {
+		return nil, err
+	}
+	if root.kind != '{' {
+		return nil, errUnsupported
+	}
+	v := strconv.Itoa(version)
+	if m := root.member("version"); m != nil {
+		return []byte(splice(s, m.val.start, m.val.end, v)), nil
+	}
+	if len(root.members) == 0 {
+		return []byte(insertMember(s, root, "version", v)), nil
+	}
+	// first member, like Marshal writes it
+	first := root.members[0].keyStart
+	sep := " "
+	if strings.Contains(s[root.start:first], "\n") {
+		sep = "\n" + lineIndent(s, first)
+	}
+	return []byte(splice(s, first, first, jsonString("version")+": "+v+","+sep)), nil
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Layer names, lowest precedence first.
+const (
+	LayerSystem  = "system"
+	LayerTeam    = "team"
+	LayerUser    = "user"
+	LayerProject = "project"
+)
+
+// TeamEnv names the team layer: a config file a team shares, e.g. in a
+// cloned repository or on a network share. Unset means no team layer.
+const TeamEnv = "GPX_TEAM_CONFIG"
+
+// SystemDir holds the system layer, e.g. corporate profiles shipped by a
+// platform team. It is a variable so tests can point it elsewhere.
+var SystemDir = "/etc/gpx"
+
+// ProjectDir is the directory of a repository that holds its config layer
+// (.gpx/config.json etc.). It shares the name with dotgpx.FileName: a
+// repository uses either the directory or the file.
+const ProjectDir = ".gpx"
+
+// Layer is one config file taking part in a merge.
+type Layer struct {
+	Name   string
+	Path   string
+	Config *Config
+}
+
+// FindProject walks up from dir and returns the nearest project layer
+// file, or "" if there is none.
+func FindProject(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("abs %s: %w", dir, err)
+	}
+	for {
+		if p := FindIn(filepath.Join(dir, ProjectDir)); p != "" {
+			return p, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Layered is the merged view of several layers.
+type Layered struct {
+	Config *Config
+	Layers []Layer // lowest precedence first
+
+	// origin maps profile -> key -> index in Layers of the layer that set it.
+	origin map[string]map[string]int
+}
+
+// Merge combines layers, later ones taking precedence: profiles are merged
+// key by key, and a later extends entry for a profile (or backup policy)
+// replaces an earlier one.
+// The result is checked with Validate.
+func Merge(layers []Layer) (*Layered, error) {
+	l := &Layered{
+		Config: &Config{Version: CurrentVersion, Profiles: map[string]map[string]string{}},
+		Layers: layers,
+		origin: map[string]map[string]int{},
+	}
+	for i, layer := range layers {
+		for name, vars := range layer.Config.Profiles {
+			p, ok := l.Config.Profiles[name]
+			if !ok {
+				p = map[string]string{}
+				l.Config.Profiles[name] = p
+				l.origin[name] = map[string]int{}
+			}
+			for k, v := range vars {
+				p[k] = v
+				l.origin[name][k] = i
+			}
+		}
+		for name, parents := range layer.Config.Extends {
+			if l.Config.Extends == nil {
+				l.Config.Extends = map[string][]string{}
+			}
+			l.Config.Extends[name] = parents
+		}
+		if layer.Config.Backup != nil {
+			l.Config.Backup = layer.Config.Backup
+		}
+	}
+	if err := Validate(l.Config); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Origin returns the layer that set key in the profile's own variables.
+func (l *Layered) Origin(profile, key string) (Layer, bool) {
+	i, ok := l.origin[profile][key]
+	if !ok {
+		return Layer{}, false
+	}
+	return l.Layers[i], true
+}
+
+// ProfileLayers returns the layers that define the profile.
+func (l *Layered) ProfileLayers(profile string) []Layer {
+	var out []Layer
+	for _, layer := range l.Layers {
+		if _, ok := layer.Config.Profiles[profile]; ok {
+			out = append(out, layer)
+		}
+	}
+	return out
+}
+
+// Replace returns the merge with the layer of the same name swapped for layer,
+// adding the layer at its place in the order if it was not there.
+func (l *Layered) Replace(layer Layer) (*Layered, error) {
+	rank := map[string]int{LayerSystem: 0, LayerTeam: 1, LayerUser: 2, LayerProject: 3}
+	var out []Layer
+	added := false
+	for _, cur := range l.Layers {
+		if !added && rank[cur.Name] >= rank[layer.Name] {
+			out = append(out, layer)
+			added = true
+			if cur.Name == layer.Name {
+				continue
+			}
+		}
+		out = append(out, cur)
+	}
+	if !added {
+		out = append(out, layer)
+	}
+	return Merge(out)
+}
+
+// EnsureFile creates an empty config at path if it does not exist yet.
+func EnsureFile(path string) error {
+	if _, err := os.Stat(path); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	cfg := &Config{Version: CurrentVersion, Profiles: map[string]map[string]string{}}
+	return SaveAs(path, cfg, CodecFor(path, nil))
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testLayer(name string, profiles map[string]map[string]string, extends map[string][]string) Layer {
+	return Layer{
+		Name:   name,
+		Path:   "/" + name + "/config.json",
+		Config: &Config{Version: CurrentVersion, Profiles: profiles, Extends: extends},
+	}
+}
+
+func TestMerge_LaterLayersWin(t *testing.T) {
+	system := testLayer(LayerSystem, map[string]map[string]string{
+		"corp": {"GOPROXY": "https://proxy.corp", "GOPRIVATE": "corp.com"},
+		"base": {"GOTOOLCHAIN": "auto"},
+	}, map[string][]string{"corp": {"base"}})
+	user := testLayer(LayerUser, map[string]map[string]string{
+		"corp": {"GOPROXY": "direct"},
+		"mine": {"GOFLAGS": "-mod=mod"},
+	}, map[string][]string{"corp": {}})
+
+	l, err := Merge([]Layer{system, user})
+	if err != nil {
+		t.Fatalf("Merge error: %v", err)
+	}
+	want := map[string]map[string]string{
+		"corp": {"GOPROXY": "direct", "GOPRIVATE": "corp.com"},
+		"base": {"GOTOOLCHAIN": "auto"},
+		"mine": {"GOFLAGS": "-mod=mod"},
+	}
+	if !reflect.DeepEqual(l.Config.Profiles, want) {
+		t.Fatalf("Profiles = %v, want %v", l.Config.Profiles, want)
+	}
+	if got := l.Config.Extends["corp"]; len(got) != 0 {
+		t.Fatalf("Extends[corp] = %v, want the user layer's empty list", got)
+	}
+
+	for _, tt := range []struct{ profile, key, layer string }{
+		{"corp", "GOPROXY", LayerUser},
+		{"corp", "GOPRIVATE", LayerSystem},
+		{"mine", "GOFLAGS", LayerUser},
+	} {
+		got, ok := l.Origin(tt.profile, tt.key)
+		if !ok || got.Name != tt.layer {
+			t.Errorf("Origin(%s, %s) = %q, %v; want %q", tt.profile, tt.key, got.Name, ok, tt.layer)
+		}
+	}
+	if _, ok := l.Origin("corp", "GOFLAGS"); ok {
+		t.Error("Origin of a missing key reported a layer")
+	}
+	if got := l.ProfileLayers("corp"); len(got) != 2 {
+		t.Errorf("ProfileLayers(corp) = %d layers, want 2", len(got))
+	}
+}
+
+func TestMerge_ChecksCrossLayerReferences(t *testing.T) {
+	system := testLayer(LayerSystem, map[string]map[string]string{"corp": {}}, nil)
+	user := testLayer(LayerUser, map[string]map[string]string{"mine": {}}, map[string][]string{"mine": {"corp"}})
+	if _, err := Merge([]Layer{system, user}); err != nil {
+		t.Fatalf("extending a profile of a lower layer: %v", err)
+	}
+	if _, err := Merge([]Layer{user}); err == nil {
+		t.Fatal("Merge accepted extends of a missing profile")
+	}
+}
+
+func TestReplace_KeepsLayerOrder(t *testing.T) {
+	system := testLayer(LayerSystem, map[string]map[string]string{"corp": {"GOPROXY": "a"}}, nil)
+	project := testLayer(LayerProject, map[string]map[string]string{"corp": {"GOPROXY": "c"}}, nil)
+	l, err := Merge([]Layer{system, project})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := testLayer(LayerUser, map[string]map[string]string{"corp": {"GOPROXY": "b"}}, nil)
+	got, err := l.Replace(user)
+	if err != nil {
+		t.Fatalf("Replace error: %v", err)
+	}
+	var names []string
+	for _, layer := range got.Layers {
+		names = append(names, layer.Name)
+	}
+	if want := []string{LayerSystem, LayerUser, LayerProject}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("layers = %v, want %v", names, want)
+	}
+	if v := got.Config.Profiles["corp"]["GOPROXY"]; v != "c" {
+		t.Fatalf("GOPROXY = %q, want the project value", v)
+	}
+
+	team := testLayer(LayerTeam, map[string]map[string]string{"corp": {"GOFLAGS": "t"}}, nil)
+	if got, err = got.Replace(team); err != nil {
+		t.Fatalf("Replace error: %v", err)
+	}
+	names = names[:0]
+	for _, layer := range got.Layers {
+		names = append(names, layer.Name)
+	}
+	if want := []string{LayerSystem, LayerTeam, LayerUser, LayerProject}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("layers = %v, want %v", names, want)
+	}
+}
+
+func TestFindProject(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := FindProject(sub); err != nil || got != "" {
+		t.Fatalf("FindProject without a project = %q, %v", got, err)
+	}
+
+	want := filepath.Join(root, ProjectDir, "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(want), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(want, []byte("version: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := FindProject(sub); err != nil || got != want {
+		t.Fatalf("FindProject = %q, %v; want %q", got, err, want)
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// CurrentVersion is the config schema version this gpx reads and writes.
+// Files without a version field are version 0.
+const CurrentVersion = 1
+
+// migration upgrades a config document from version from to from+1.
+// It works on the generic document, so it can handle fields that no longer
+// exist in Config.
+type migration struct {
+	from    int
+	summary string
+	// rewrite is set when the document structure changes: the file is then
+	// re-encoded as a whole instead of patched in place.
+	rewrite bool
+	apply   func(doc map[string]any) error
+}
+
+// migrations must cover every version below CurrentVersion, in order.
+var migrations = []migration{
+	{
+		from:    0,
+		summary: "add the version field",
+		apply:   func(map[string]any) error { return nil },
+	},
+}
+
+// VersionError reports a config written for a newer gpx.
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("config version %d is newer than this gpx supports (%d); upgrade gpx", e.Version, CurrentVersion)
+}
+
+// migrate upgrades doc in place to CurrentVersion. It returns the version
+// the document had and the steps applied.
+func migrate(tree any) (int, []migration, error) {
+	doc, ok := tree.(map[string]any)
+	if !ok {
+		return 0, nil, fmt.Errorf("top level must be a mapping")
+	}
+	from, err := docVersion(doc)
+	if err != nil {
+		return 0, nil, err
+	}
+	if from > CurrentVersion {
+		return from, nil, &VersionError{Version: from}
+	}
+
+	var steps []migration
+	for v := from; v < CurrentVersion; v++ {
+		if v >= len(migrations) || migrations[v].from != v {
+			return from, nil, fmt.Errorf("no migration from version %d", v)
+		}
+		m := migrations[v]
+		if err := m.apply(doc); err != nil {
+			return from, nil, fmt.Errorf("migrate version %d to %d: %w", v, v+1, err)
+		}
+		steps = append(steps, m)
+	}
+	doc["version"] = CurrentVersion
+	return from, steps, nil
+}
+
+// docVersion reads the version field of a document; missing means 0.
+func docVersion(doc map[string]any) (int, error) {
+	var (
+		v   int
+		err error
+	)
+	switch x := doc["version"].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		v = x
+	case float64:
+		v = int(x)
+		if float64(v) != x {
+			err = fmt.Errorf("not an integer")
+		}
+	case json.Number:
+		var n int64
+		n, err = x.Int64()
+		v = int(n)
+	case string:
+		v, err = strconv.Atoi(x)
+	default:
+		err = fmt.Errorf("unexpected %T", x)
+	}
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("bad version %v", doc["version"])
+	}
+	return v, nil
+}
+
+type MigrateResult struct {
+	From, To int
+	Steps    []string
+	Content  []byte // the rewritten file; nil when it is up to date
+}
+
+// Migrate upgrades the config file at path to CurrentVersion, keeping
+// comments and ordering where the steps allow it. With dryRun the file
+// is left untouched and Content shows what would be written.
+func Migrate(path string, dryRun bool) (*MigrateResult, error) {
+	f, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	res := &MigrateResult{From: f.from, To: CurrentVersion}
+	if len(f.steps) == 0 {
+		return res, nil
+	}
+	for _, s := range f.steps {
+		res.Steps = append(res.Steps, fmt.Sprintf("%d -> %d: %s", s.from, s.from+1, s.summary))
+	}
+
+	e := newEditor(f)
+	if res.Content, err = e.encode(true); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return res, nil
+	}
+	if err := writeFile(path, res.Content); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTemp(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_MigratesLegacyConfig(t *testing.T) {
+	path := writeTemp(t, "config.json", `{"profiles": {"public": {"GOPROXY": "direct"}}}`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Fatalf("Version = %d, want %d", cfg.Version, CurrentVersion)
+	}
+	// Load never writes
+	if got := readFile(t, path); got != `{"profiles": {"public": {"GOPROXY": "direct"}}}` {
+		t.Fatalf("Load changed the file: %s", got)
+	}
+}
+
+func TestLoad_RejectsNewerVersion(t *testing.T) {
+	for name, src := range map[string]string{
+		"config.json": `{"version": 99, "profiles": {}}`,
+		"config.yaml": "version: 99\nprofiles: {}\n",
+		"config.toml": "version = 99\n[profiles]\n",
+	} {
+		_, err := Load(writeTemp(t, name, src))
+		var verr *VersionError
+		if !errors.As(err, &verr) || verr.Version != 99 {
+			t.Fatalf("%s: Load error = %v, want VersionError", name, err)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			name: "config.json",
+			src:  "{\n  \"profiles\": {\n    \"public\": {}\n  }\n}\n",
+			want: "{\n  \"version\": 1,\n  \"profiles\": {\n    \"public\": {}\n  }\n}\n",
+		},
+		{
+			name: "config.yaml",
+			src:  "# mine\nprofiles:\n  public: {}  # keep\n",
+			want: "version: 1\n# mine\nprofiles:\n  public: {}  # keep\n",
+		},
+		{
+			name: "config.toml",
+			src:  "# top\n\n# public proxy\n[profiles.public]\n",
+			want: "# top\n\nversion = 1\n\n# public proxy\n[profiles.public]\n",
+		},
+	}
+	for _, tt := range tests {
+		path := writeTemp(t, tt.name, tt.src)
+
+		res, err := Migrate(path, true)
+		if err != nil {
+			t.Fatalf("%s: Migrate dry-run error: %v", tt.name, err)
+		}
+		if res.From != 0 || res.To != CurrentVersion || len(res.Steps) != 1 {
+			t.Fatalf("%s: result = %+v", tt.name, res)
+		}
+		if string(res.Content) != tt.want {
+			t.Fatalf("%s: content =\n%s\nwant\n%s", tt.name, res.Content, tt.want)
+		}
+		if got := readFile(t, path); got != tt.src {
+			t.Fatalf("%s: dry-run wrote the file", tt.name)
+		}
+
+		if _, err := Migrate(path, false); err != nil {
+			t.Fatalf("%s: Migrate error: %v", tt.name, err)
+		}
+		if got := readFile(t, path); got != tt.want {
+			t.Fatalf("%s: file =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+		res, err = Migrate(path, false)
+		if err != nil || len(res.Steps) != 0 || res.Content != nil {
+			t.Fatalf("%s: second Migrate = %+v, %v; want up to date", tt.name, res, err)
+		}
+	}
+}
+
+func TestMigrations_CoverEveryVersion(t *testing.T) {
+	if len(migrations) != CurrentVersion {
+		t.Fatalf("%d migrations for version %d", len(migrations), CurrentVersion)
+	}
+	for i, m := range migrations {
+		if m.from != i {
+			t.Fatalf("migrations[%d].from = %d", i, m.from)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ResolvedVar is a profile variable after inheritance, together with
+// the profile that actually defined it.
+type ResolvedVar struct {
+	Value  string
+	Origin string
+}
+
+// Resolve merges the profile with its parents (see Config.Extends).
+// Parents are merged in declaration order, each one fully resolved first;
+// later parents override earlier ones and the profile itself overrides all of them.
+func (c *Config) Resolve(name string) (map[string]ResolvedVar, error) {
+	return c.resolve(name, nil)
+}
+
+// ResolveVars is like Resolve but drops origin information.
+func (c *Config) ResolveVars(name string) (map[string]string, error) {
+	rv, err := c.Resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(rv))
+	for k, v := range rv {
+		out[k] = v.Value
+	}
+	return out, nil
+}
+
+func (c *Config) resolve(name string, chain []string) (map[string]ResolvedVar, error) {
+	for i, n := range chain {
+		if n == name {
+			cycle := append(append([]string{}, chain[i:]...), name)
+			return nil, fmt.Errorf("inheritance cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	vars, ok := c.Profiles[name]
+	if !ok {
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("profile %q not found", name)
+		}
+		return nil, fmt.Errorf("profile %q extends missing profile %q", chain[len(chain)-1], name)
+	}
+
+	chain = append(chain, name)
+	out := map[string]ResolvedVar{}
+	for _, parent := range c.Extends[name] {
+		pv, err := c.resolve(parent, chain)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range pv {
+			out[k] = v
+		}
+	}
+	for k, v := range vars {
+		out[k] = ResolvedVar{Value: v, Origin: name}
+	}
+	return out, nil
+}
+
+// Children returns profiles that directly extend the given one.
+func (c *Config) Children(name string) []string {
+	var out []string
+	for child, parents := range c.Extends {
+		for _, p := range parents {
+			if p == name {
+				out = append(out, child)
+				break
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolve_ChildOverridesParents(t *testing.T) {
+	cfg := &Config{
+		Profiles: map[string]map[string]string{
+			"corp":    {"GOPROXY": "https://proxy.corp.local", "GOPRIVATE": "corp.local/*"},
+			"offline": {"GOFLAGS": "-mod=mod", "GOPROXY": "off"},
+			"corp-ci": {"GOFLAGS": "-mod=readonly"},
+		},
+		Extends: map[string][]string{
+			"corp-ci": {"corp", "offline"},
+		},
+	}
+
+	got, err := cfg.Resolve("corp-ci")
+	if err != nil {
+		t.Fatalf("Resolve error: %v", err)
+	}
+	want := map[string]ResolvedVar{
+		"GOPROXY":   {Value: "off", Origin: "offline"},
+		"GOPRIVATE": {Value: "corp.local/*", Origin: "corp"},
+		"GOFLAGS":   {Value: "-mod=readonly", Origin: "corp-ci"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Resolve got %+v, want %+v", got, want)
+	}
+}
+
+func TestValidate_ExtendsMissingParent(t *testing.T) {
+	cfg := &Config{
+		Profiles: map[string]map[string]string{"a": {}},
+		Extends:  map[string][]string{"a": {"nope"}},
+	}
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "missing profile") {
+		t.Fatalf("expected missing parent error, got %v", err)
+	}
+}
+
+func TestValidate_ExtendsCycle(t *testing.T) {
+	cfg := &Config{
+		Profiles: map[string]map[string]string{"a": {}, "b": {}, "c": {}},
+		Extends: map[string][]string{
+			"a": {"b"},
+			"b": {"c"},
+			"c": {"a"},
+		},
+	}
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type tomlCodec struct{}
+
+func (tomlCodec) Name() string { return "toml" }
+func (tomlCodec) Ext() string  { return ".toml" }
+
+// Marshal writes the version, one [profiles.NAME] table per profile,
+// an [extends] table and a [backup] table.
+func (tomlCodec) Marshal(cfg *Config) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "version = %d\n\n", cfg.Version)
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		b.WriteString("[profiles]\n")
+	}
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[profiles.%s]\n", tomlKey(name))
+		vars := cfg.Profiles[name]
+		keys := make([]string, 0, len(vars))
+		for k := range vars {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s = %s\n", tomlKey(k), tomlString(vars[k]))
+		}
+	}
+
+	if len(cfg.Extends) > 0 {
+		b.WriteString("\n[extends]\n")
+		children := make([]string, 0, len(cfg.Extends))
+		for c := range cfg.Extends {
+			children = append(children, c)
+		}
+		sort.Strings(children)
+		for _, c := range children {
+			parents := make([]string, 0, len(cfg.Extends[c]))
+			for _, p := range cfg.Extends[c] {
+				parents = append(parents, tomlString(p))
+			}
+			fmt.Fprintf(&b, "%s = [%s]\n", tomlKey(c), strings.Join(parents, ", "))
+		}
+	}
+
+	if p := cfg.Backup; p != nil && *p != (BackupPolicy{}) {
+		b.WriteString("\n[backup]\n")
+		if p.Keep != 0 {
+			fmt.Fprintf(&b, "keep = %d\n", p.Keep)
+		}
+		if p.MaxAge != "" {
+			fmt.Fprintf(&b, "max_age = %s\n", tomlString(p.MaxAge))
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func (c tomlCodec) Unmarshal(b []byte, cfg *Config) error {
+	return unmarshal(c, b, cfg)
+}
+
+func (tomlCodec) tree(b []byte) (any, error) {
+	return parseTOML(string(b))
+}
+
+// tomlKey quotes a key unless it is a valid bare key.
+func tomlKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for _, r := range k {
+		if !(r == '_' || r == '-' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9')) {
+			return tomlString(k)
+		}
+	}
+	return k
+}
+
+// The TOML reader covers what a gpx config needs: [tables] with dotted and
+// quoted keys, key = value pairs, basic and literal strings, (multi-line)
+// arrays of strings and comments. Other scalars (numbers, booleans) are kept
+// as their literal text.
+
+func parseTOML(src string) (map[string]any, error) {
+	root := map[string]any{}
+	cur := root
+	var curPath []string
+	// defined maps each table defined by a [header] or an inline table to
+	// its line: TOML does not allow defining a table twice.
+	defined := map[string]int{}
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		num := i + 1
+		ln := strings.TrimSpace(stripTOMLComment(strings.TrimRight(lines[i], "\r")))
+		if ln == "" {
+			continue
+		}
+
+		if strings.HasPrefix(ln, "[[") {
+			return nil, fmt.Errorf("toml line %d: arrays of tables are not supported", num)
+		}
+		if strings.HasPrefix(ln, "[") {
+			if !strings.HasSuffix(ln, "]") {
+				return nil, fmt.Errorf("toml line %d: bad table header", num)
+			}
+			path, err := parseTOMLKey(ln[1 : len(ln)-1])
+			if err != nil {
+				return nil, fmt.Errorf("toml line %d: %w", num, err)
+			}
+			if prev, ok := defined[tomlPathKey(path)]; ok {
+				return nil, fmt.Errorf("toml line %d: table [%s] is already defined on line %d", num, ln[1:len(ln)-1], prev)
+			}
+			defined[tomlPathKey(path)] = num
+			if cur, err = tomlTable(root, path); err != nil {
+				return nil, fmt.Errorf("toml line %d: %w", num, err)
+			}
+			curPath = path
+			continue
+		}
+
+		eq := tomlKeyEnd(ln)
+		if eq < 0 {
+			return nil, fmt.Errorf("toml line %d: expected key = value", num)
+		}
+		path, err := parseTOMLKey(ln[:eq])
+		if err != nil {
+			return nil, fmt.Errorf("toml line %d: %w", num, err)
+		}
+		raw := strings.TrimSpace(ln[eq+1:])
+		// arrays may span lines until the closing bracket
+		for strings.HasPrefix(raw, "[") && !tomlArrayClosed(raw) && i+1 < len(lines) {
+			i++
+			raw += " " + strings.TrimSpace(stripTOMLComment(strings.TrimRight(lines[i], "\r")))
+		}
+		v, err := parseTOMLValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("toml line %d: %w", num, err)
+		}
+
+		t, err := tomlTable(cur, path[:len(path)-1])
+		if err != nil {
+			return nil, fmt.Errorf("toml line %d: %w", num, err)
+		}
+		last := path[len(path)-1]
+		if _, dup := t[last]; dup {
+			return nil, fmt.Errorf("toml line %d: duplicate key %q", num, last)
+		}
+		t[last] = v
+		if _, ok := v.(map[string]any); ok {
+			defined[tomlPathKey(append(slices.Clone(curPath), path...))] = num
+		}
+	}
+	return root, nil
+}
+
+// tomlPathKey joins a table path into a map key.
+func tomlPathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// tomlTable walks (and creates) nested tables along path.
+func tomlTable(root map[string]any, path []string) (map[string]any, error) {
+	t := root
+	for _, k := range path {
+		next, ok := t[k]
+		if !ok {
+			m := map[string]any{}
+			t[k] = m
+			t = m
+			continue
+		}
+		m, ok := next.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("key %q is not a table", k)
+		}
+		t = m
+	}
+	return t, nil
+}
+
+// parseTOMLKey parses a (dotted) key: bare, "basic" or 'literal' parts.
+func parseTOMLKey(s string) ([]string, error) {
+	var out []string
+	s = strings.TrimSpace(s)
+	for {
+		var part string
+		switch {
+		case strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'"):
+			end := quotedEnd(s)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted key")
+			}
+			v, err := parseTOMLString(s[:end+1])
+			if err != nil {
+				return nil, err
+			}
+			part, s = v, s[end+1:]
+		default:
+			i := strings.IndexByte(s, '.')
+			if i < 0 {
+				i = len(s)
+			}
+			part, s = strings.TrimSpace(s[:i]), s[i:]
+			if part == "" || tomlKey(part) != part {
+				return nil, fmt.Errorf("bad key %q", part)
+			}
+		}
+		out = append(out, part)
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return out, nil
+		}
+		if s[0] != '.' {
+			return nil, fmt.Errorf("unexpected %q in key", s)
+		}
+		s = strings.TrimSpace(s[1:])
+	}
+}
+
+func parseTOMLValue(s string) (any, error) {
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("missing value")
+	case strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''"):
+		return nil, fmt.Errorf("multi-line strings are not supported")
+	case strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'"):
+		if end := quotedEnd(s); end != len(s)-1 {
+			return nil, fmt.Errorf("unexpected text after string: %s", s)
+		}
+		return parseTOMLString(s)
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		items, err := splitFlow(s[1:len(s)-1], 0)
+		if err != nil {
+			return nil, err
+		}
+		out := []any{}
+		for _, it := range items {
+			v, err := parseTOMLValue(it)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case strings.HasPrefix(s, "{"):
+		if !strings.HasSuffix(s, "}") {
+			return nil, fmt.Errorf("unterminated inline table")
+		}
+		items, err := splitFlow(s[1:len(s)-1], 0)
+		if err != nil {
+			return nil, err
+		}
+		out := map[string]any{}
+		for _, it := range items {
+			eq := tomlKeyEnd(it)
+			if eq < 0 {
+				return nil, fmt.Errorf("expected key = value in inline table")
+			}
+			path, err := parseTOMLKey(it[:eq])
+			if err != nil {
+				return nil, err
+			}
+			v, err := parseTOMLValue(strings.TrimSpace(it[eq+1:]))
+			if err != nil {
+				return nil, err
+			}
+			t, err := tomlTable(out, path[:len(path)-1])
+			if err != nil {
+				return nil, err
+			}
+			t[path[len(path)-1]] = v
+		}
+		return out, nil
+	}
+	return s, nil
+}
+
+func parseTOMLString(s string) (string, error) {
+	if strings.HasPrefix(s, "'") {
+		return s[1 : len(s)-1], nil
+	}
+	v, err := unescape(s[1:len(s)-1], tomlEscapes, tomlHex)
+	if err != nil {
+		return "", fmt.Errorf("bad string %s: %w", s, err)
+	}
+	return v, nil
+}
+
+// tomlEscapes and tomlHex are the escapes of TOML basic strings
+// (see unescape).
+var (
+	tomlEscapes = map[byte]string{
+		'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", '"': `"`, '\\': `\`,
+	}
+	tomlHex = map[byte]int{'u': 4, 'U': 8}
+)
+
+// tomlString renders s as a TOML basic string, using only the escapes TOML
+// defines; other control characters become \uXXXX.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// tomlKeyEnd returns the index of the "=" separating key and value.
+func tomlKeyEnd(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			end := quotedEnd(s[i:])
+			if end < 0 {
+				return -1
+			}
+			i += end
+		case '=':
+			return i
+		}
+	}
+	return -1
+}
+
+func tomlArrayClosed(s string) bool {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			end := quotedEnd(s[i:])
+			if end < 0 {
+				return false
+			}
+			i += end
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+	}
+	return depth == 0
+}
+
+// stripTOMLComment removes a trailing "# ..." outside of strings.
+func stripTOMLComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			if end := quotedEnd(s[i:]); end > 0 {
+				i += end
+			}
+		case '#':
+			return s[:i]
+		}
+	}
+	return s
+}
@@ -0,0 +1,334 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+func (tomlCodec) patcher() patcher { return tomlPatcher{} }
+
+// tomlPatcher edits TOML by tables: profiles must be [profiles.NAME] tables
+// and parents an [extends] table, as Marshal writes them. Other layouts
+// (inline tables, dotted keys) are left to a full rewrite.
+type tomlPatcher struct{}
+
+type tomlDoc struct {
+	lines []string // with their "\n"
+}
+
+// tomlSection is a [table] with its key/value entries.
+type tomlSection struct {
+	path    []string
+	header  int // -1 for the root table
+	end     int // last content line + 1
+	entries []tomlEntry
+}
+
+type tomlEntry struct {
+	key  []string
+	line int
+	end  int // multi-line arrays span several lines
+}
+
+func parseTOMLDoc(src []byte) (*tomlDoc, []tomlSection, error) {
+	lines := strings.SplitAfter(string(src), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	d := &tomlDoc{lines: lines}
+
+	sections := []tomlSection{{header: -1, end: 0}}
+	for i := 0; i < len(lines); i++ {
+		ln := d.text(i)
+		if ln == "" {
+			continue
+		}
+		cur := &sections[len(sections)-1]
+		if strings.HasPrefix(ln, "[[") {
+			return nil, nil, errUnsupported
+		}
+		if strings.HasPrefix(ln, "[") {
+			path, err := parseTOMLKey(strings.TrimSuffix(ln[1:], "]"))
+			if err != nil {
+				return nil, nil, err
+			}
+			sections = append(sections, tomlSection{path: path, header: i, end: i + 1})
+			continue
+		}
+		eq := tomlKeyEnd(ln)
+		if eq < 0 {
+			return nil, nil, errUnsupported
+		}
+		key, err := parseTOMLKey(ln[:eq])
+		if err != nil {
+			return nil, nil, err
+		}
+		e := tomlEntry{key: key, line: i, end: i + 1}
+		raw := strings.TrimSpace(ln[eq+1:])
+		for strings.HasPrefix(raw, "[") && !tomlArrayClosed(raw) && e.end < len(lines) {
+			raw += " " + d.text(e.end)
+			e.end++
+		}
+		i = e.end - 1
+		cur.entries = append(cur.entries, e)
+		cur.end = e.end
+	}
+	return d, sections, nil
+}
+
+func (d *tomlDoc) bytes() []byte { return []byte(strings.Join(d.lines, "")) }
+
+// text returns line i without comment and surrounding blanks.
+func (d *tomlDoc) text(i int) string {
+	return strings.TrimSpace(stripTOMLComment(strings.TrimRight(d.lines[i], "\r\n")))
+}
+
+func findTOMLSection(sections []tomlSection, path ...string) *tomlSection {
+	for i := range sections {
+		if sections[i].header >= 0 && equalPath(sections[i].path, path) {
+			return &sections[i]
+		}
+	}
+	return nil
+}
+
+func (s *tomlSection) entry(key string) *tomlEntry {
+	for i := range s.entries {
+		if equalPath(s.entries[i].key, []string{key}) {
+			return &s.entries[i]
+		}
+	}
+	return nil
+}
+
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// comment returns the trailing comment of line i, with the blanks before it.
+func (d *tomlDoc) comment(i int) string {
+	body := strings.TrimRight(d.lines[i], "\r\n")
+	s := stripTOMLComment(body)
+	if len(s) == len(body) {
+		return ""
+	}
+	return s[len(strings.TrimRight(s, " \t")):] + body[len(s):]
+}
+
+func (d *tomlDoc) lineEnd(i int) string {
+	return d.lines[i][len(strings.TrimRight(d.lines[i], "\r\n")):]
+}
+
+// setValue rewrites entry e as "key = value", keeping its key text,
+// indentation and the comment of its first line.
+func (d *tomlDoc) setValue(e tomlEntry, value string) {
+	raw := strings.TrimRight(d.lines[e.line], "\r\n")
+	key := strings.TrimRight(raw[:tomlKeyEnd(raw)], " \t")
+	line := key + " = " + value + d.comment(e.line) + d.lineEnd(e.end-1)
+	d.remove(e.line, e.end)
+	d.insert(e.line, line)
+}
+
+func (d *tomlDoc) renameEntry(e tomlEntry, key string) {
+	raw := strings.TrimRight(d.lines[e.line], "\r\n")
+	indent := raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]
+	eq := tomlKeyEnd(raw)
+	d.lines[e.line] = indent + tomlKey(key) + " " + strings.TrimLeft(raw[eq:], " \t") + d.lineEnd(e.line)
+}
+
+// entryIndent returns the indentation used by the section's entries.
+func (d *tomlDoc) entryIndent(s *tomlSection) string {
+	if len(s.entries) == 0 {
+		return ""
+	}
+	raw := d.lines[s.entries[0].line]
+	return raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]
+}
+
+func (d *tomlDoc) insert(at int, lines ...string) {
+	if at > 0 && !strings.HasSuffix(d.lines[at-1], "\n") {
+		d.lines[at-1] += "\n"
+	}
+	d.lines = append(d.lines[:at], append(lines, d.lines[at:]...)...)
+}
+
+func (d *tomlDoc) remove(from, to int) {
+	d.lines = append(d.lines[:from], d.lines[to:]...)
+}
+
+// blank reports whether line i is empty (not a comment).
+func (d *tomlDoc) blank(i int) bool {
+	return i >= 0 && i < len(d.lines) && strings.TrimSpace(d.lines[i]) == ""
+}
+
+// appendSection adds a table after line at, separated by a blank line.
+func (d *tomlDoc) appendSection(at int, lines ...string) {
+	if at > 0 && !d.blank(at-1) {
+		lines = append([]string{"\n"}, lines...)
+	}
+	if at < len(d.lines) && !d.blank(at) {
+		lines = append(lines, "\n")
+	}
+	d.insert(at, lines...)
+}
+
+func tomlList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, it := range items {
+		quoted[i] = tomlString(it)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func (tomlPatcher) profile(src []byte, name string) (*tomlDoc, *tomlSection, error) {
+	d, sections, err := parseTOMLDoc(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := findTOMLSection(sections, "profiles", name)
+	if s == nil {
+		return nil, nil, errUnsupported
+	}
+	return d, s, nil
+}
+
+func (p tomlPatcher) setVar(src []byte, profile, key, value string) ([]byte, error) {
+	d, s, err := p.profile(src, profile)
+	if err != nil {
+		return nil, err
+	}
+	if e := s.entry(key); e != nil {
+		d.setValue(*e, tomlString(value))
+		return d.bytes(), nil
+	}
+	d.insert(s.end, d.entryIndent(s)+tomlKey(key)+" = "+tomlString(value)+"\n")
+	return d.bytes(), nil
+}
+
+func (p tomlPatcher) unsetVar(src []byte, profile, key string) ([]byte, error) {
+	d, s, err := p.profile(src, profile)
+	if err != nil {
+		return nil, err
+	}
+	e := s.entry(key)
+	if e == nil {
+		return nil, errUnsupported
+	}
+	d.remove(e.line, e.end)
+	return d.bytes(), nil
+}
+
+func (tomlPatcher) addProfile(src []byte, name string) ([]byte, error) {
+	d, sections, err := parseTOMLDoc(src)
+	if err != nil {
+		return nil, err
+	}
+	header := "[profiles." + tomlKey(name) + "]\n"
+
+	// after the last profiles table; else before [extends]; else at the end
+	at := -1
+	for _, s := range sections {
+		if s.header >= 0 && len(s.path) > 0 && s.path[0] == "profiles" {
+			at = s.end
+		}
+	}
+	if at < 0 {
+		if ext := findTOMLSection(sections, "extends"); ext != nil {
+			at = ext.header
+		} else {
+			at = len(d.lines)
+		}
+	}
+	d.appendSection(at, header)
+	return d.bytes(), nil
+}
+
+func (p tomlPatcher) removeProfile(src []byte, name string) ([]byte, error) {
+	d, s, err := p.profile(src, name)
+	if err != nil {
+		return nil, err
+	}
+	d.remove(s.header, s.end)
+	if d.blank(s.header) && (s.header == 0 || d.blank(s.header-1)) {
+		d.remove(s.header, s.header+1)
+	}
+	return d.bytes(), nil
+}
+
+func (p tomlPatcher) renameProfile(src []byte, oldName, newName string) ([]byte, error) {
+	d, s, err := p.profile(src, oldName)
+	if err != nil {
+		return nil, err
+	}
+	raw := d.lines[s.header]
+	indent := raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]
+	d.lines[s.header] = indent + "[profiles." + tomlKey(newName) + "]" + d.comment(s.header) + d.lineEnd(s.header)
+
+	_, sections, err := parseTOMLDoc(d.bytes())
+	if err != nil {
+		return nil, err
+	}
+	if ext := findTOMLSection(sections, "extends"); ext != nil {
+		if e := ext.entry(oldName); e != nil {
+			d.renameEntry(*e, newName)
+		}
+	}
+	return d.bytes(), nil
+}
+
+func (tomlPatcher) setExtends(src []byte, name string, parents []string) ([]byte, error) {
+	d, sections, err := parseTOMLDoc(src)
+	if err != nil {
+		return nil, err
+	}
+	ext := findTOMLSection(sections, "extends")
+	if ext == nil {
+		if len(parents) == 0 {
+			return src, nil
+		}
+		d.appendSection(len(d.lines), "[extends]\n", tomlKey(name)+" = "+tomlList(parents)+"\n")
+		return d.bytes(), nil
+	}
+
+	e := ext.entry(name)
+	switch {
+	case len(parents) == 0 && e == nil:
+		return src, nil
+	case len(parents) == 0:
+		d.remove(e.line, e.end)
+	case e == nil:
+		d.insert(ext.end, d.entryIndent(ext)+tomlKey(name)+" = "+tomlList(parents)+"\n")
+	default:
+		d.setValue(*e, tomlList(parents))
+	}
+	return d.bytes(), nil
+}
+
+func (tomlPatcher) setVersion(src []byte, version int) ([]byte, error) {
+	d, sections, err := parseTOMLDoc(src)
+	if err != nil {
+		return nil, err
+	}
+	line := "version = " + strconv.Itoa(version) + "\n"
+	root := &sections[0]
+	switch {
+	case root.entry("version") != nil:
+		d.setValue(*root.entry("version"), strconv.Itoa(version))
+	case len(root.entries) > 0:
+		d.insert(root.end, line)
+	case len(sections) == 1:
+		d.insert(len(d.lines), line)
+	default:
+		// root keys must precede the first table
+		d.insert(commentBlockStart(d.lines, sections[1].header), line, "\n")
+	}
+	return d.bytes(), nil
+}
@@ -4,10 +4,39 @@ import (
 	"fmt"
 )
 
+// Validate checks a complete config, including references between profiles.
 func Validate(cfg *Config) error {
+	if err := ValidateLayer(cfg); err != nil {
+		return err
+	}
+	for child, parents := range cfg.Extends {
+		if _, ok := cfg.Profiles[child]; !ok {
+			return fmt.Errorf("extends: profile %q not found", child)
+		}
+		for _, p := range parents {
+			if _, ok := cfg.Profiles[p]; !ok {
+				return fmt.Errorf("extends: profile %q extends missing profile %q", child, p)
+			}
+		}
+		if _, err := cfg.Resolve(child); err != nil {
+			return fmt.Errorf("extends: %w", err)
+		}
+	}
+	return nil
+}
+
+// ValidateLayer checks a config that may be one layer of several (see Merge):
+// extends may refer to profiles defined in other layers.
+func ValidateLayer(cfg *Config) error {
 	if cfg == nil {
 		return fmt.Errorf("config is nil")
 	}
+	if cfg.Version < 0 {
+		return fmt.Errorf("bad version %d", cfg.Version)
+	}
+	if cfg.Version > CurrentVersion {
+		return &VersionError{Version: cfg.Version}
+	}
 	if cfg.Profiles == nil {
 		return fmt.Errorf("profiles is missing")
 	}
@@ -24,7 +53,14 @@ func Validate(cfg *Config) error {
 			}
 		}
 	}
-	return nil
+	for child, parents := range cfg.Extends {
+		for _, p := range parents {
+			if p == "" {
+				return fmt.Errorf("extends: profile %q has an empty parent name", child)
+			}
+		}
+	}
+	return cfg.Backup.validate()
 }
 
 func validateEnvKey(key string) error {
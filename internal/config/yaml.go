@@ -0,0 +1,373 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ZeraiGR/gpx/internal/output"
+)
+
+type yamlCodec struct{}
+
+func (yamlCodec) Name() string { return "yaml" }
+func (yamlCodec) Ext() string  { return ".yaml" }
+
+func (yamlCodec) Marshal(cfg *Config) ([]byte, error) {
+	return output.MarshalYAML(cfg)
+}
+
+func (c yamlCodec) Unmarshal(b []byte, cfg *Config) error {
+	return unmarshal(c, b, cfg)
+}
+
+func (yamlCodec) tree(b []byte) (any, error) {
+	return parseYAML(string(b))
+}
+
+// The YAML reader covers the subset a gpx config needs: block mappings and
+// sequences, flow sequences/mappings of scalars, plain, single- and
+// double-quoted scalars, and comments. Scalars are always strings
+// (env values are strings anyway); only ~, null and an empty value are null.
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+func parseYAML(src string) (any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(src, "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		if strings.HasPrefix(raw, "---") || strings.HasPrefix(raw, "...") {
+			continue
+		}
+		if strings.Contains(raw, "\t") && strings.TrimLeft(raw, " ") != strings.TrimLeft(raw, " \t") {
+			return nil, fmt.Errorf("yaml line %d: tabs are not allowed for indentation", i+1)
+		}
+		text := stripYAMLComment(raw)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		lines = append(lines, yamlLine{num: i + 1, indent: indent, text: strings.TrimSpace(text)})
+	}
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+	p := &yamlParser{lines: lines}
+	v, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		ln := p.lines[p.pos]
+		return nil, fmt.Errorf("yaml line %d: unexpected indentation", ln.num)
+	}
+	return v, nil
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block parses a mapping or a sequence whose items start at exactly indent.
+func (p *yamlParser) block(indent int) (any, error) {
+	if strings.HasPrefix(p.lines[p.pos].text, "- ") || p.lines[p.pos].text == "-" {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	out := map[string]any{}
+	for p.pos < len(p.lines) {
+		ln := p.lines[p.pos]
+		if ln.indent < indent {
+			break
+		}
+		if ln.indent > indent {
+			return nil, fmt.Errorf("yaml line %d: unexpected indentation", ln.num)
+		}
+		key, rest, err := splitYAMLKey(ln.text)
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: %w", ln.num, err)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("yaml line %d: duplicate key %q", ln.num, key)
+		}
+		p.pos++
+		v, err := p.value(rest, indent, ln.num)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+func (p *yamlParser) sequence(indent int) (any, error) {
+	out := []any{}
+	for p.pos < len(p.lines) {
+		ln := p.lines[p.pos]
+		if ln.indent < indent {
+			break
+		}
+		if ln.indent > indent || !(strings.HasPrefix(ln.text, "- ") || ln.text == "-") {
+			return nil, fmt.Errorf("yaml line %d: expected a sequence item", ln.num)
+		}
+		item := strings.TrimSpace(strings.TrimPrefix(ln.text, "-"))
+		if _, _, err := splitYAMLKey(item); err == nil && !isYAMLQuoted(item) {
+			// "- key: value" starts a mapping nested in the item
+			p.lines[p.pos] = yamlLine{num: ln.num, indent: indent + 2, text: item}
+			v, err := p.mapping(indent + 2)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			continue
+		}
+		p.pos++
+		v, err := p.value(item, indent, ln.num)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// value parses what follows "key:" or "-": an inline scalar/flow value,
+// or a nested block on the following, more indented lines.
+func (p *yamlParser) value(rest string, indent, num int) (any, error) {
+	if rest != "" {
+		return parseYAMLInline(rest, num)
+	}
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return p.block(p.lines[p.pos].indent)
+	}
+	// "- key:" items: nested content may be at the same column as "-"
+	if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && strings.HasPrefix(p.lines[p.pos].text, "- ") {
+		return p.sequence(indent)
+	}
+	return nil, nil
+}
+
+func parseYAMLInline(s string, num int) (any, error) {
+	switch {
+	case s == "~" || s == "null":
+		return nil, nil
+	case s == "|" || s == ">" || strings.HasPrefix(s, "|") || strings.HasPrefix(s, ">"):
+		return nil, fmt.Errorf("yaml line %d: block scalars are not supported", num)
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("yaml line %d: unterminated flow sequence", num)
+		}
+		out := []any{}
+		items, err := splitFlow(s[1:len(s)-1], num)
+		if err != nil {
+			return nil, err
+		}
+		for _, it := range items {
+			v, err := parseYAMLScalar(it, num)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case strings.HasPrefix(s, "{"):
+		if !strings.HasSuffix(s, "}") {
+			return nil, fmt.Errorf("yaml line %d: unterminated flow mapping", num)
+		}
+		out := map[string]any{}
+		items, err := splitFlow(s[1:len(s)-1], num)
+		if err != nil {
+			return nil, err
+		}
+		for _, it := range items {
+			k, v, err := splitYAMLKey(it)
+			if err != nil {
+				return nil, fmt.Errorf("yaml line %d: %w", num, err)
+			}
+			sv, err := parseYAMLScalar(v, num)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = sv
+		}
+		return out, nil
+	}
+	return parseYAMLScalar(s, num)
+}
+
+func parseYAMLScalar(s string, num int) (any, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "" || s == "~" || s == "null":
+		return nil, nil
+	case strings.HasPrefix(s, `"`):
+		if len(s) < 2 || quotedEnd(s) != len(s)-1 {
+			return nil, fmt.Errorf("yaml line %d: bad double-quoted string %s", num, s)
+		}
+		v, err := unescape(s[1:len(s)-1], yamlEscapes, yamlHex)
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: bad double-quoted string %s: %w", num, s, err)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("yaml line %d: bad single-quoted string %s", num, s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
+
+// splitYAMLKey splits "key: value" (key may be quoted).
+func splitYAMLKey(s string) (string, string, error) {
+	if isYAMLQuoted(s) {
+		end := quotedEnd(s)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quoted key")
+		}
+		rest := s[end+1:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("expected ':' after key")
+		}
+		k, err := parseYAMLScalar(s[:end+1], 0)
+		if err != nil {
+			return "", "", err
+		}
+		return k.(string), strings.TrimSpace(rest[1:]), nil
+	}
+	i := strings.Index(s, ": ")
+	if i < 0 {
+		if strings.HasSuffix(s, ":") {
+			i = len(s) - 1
+		} else {
+			return "", "", fmt.Errorf("expected 'key: value'")
+		}
+	}
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]), nil
+}
+
+func isYAMLQuoted(s string) bool {
+	return strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'")
+}
+
+// yamlEscapes are the escapes of YAML double-quoted scalars; yamlHex
+// gives the digit count of the hex ones.
+var (
+	yamlEscapes = map[byte]string{
+		'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+		'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': `"`,
+		'/': "/", '\\': `\`, 'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+	}
+	yamlHex = map[byte]int{'x': 2, 'u': 4, 'U': 8}
+)
+
+// unescape decodes the backslash escapes of a double-quoted string body,
+// for the YAML and TOML readers: escapes maps single-character escapes to
+// their text, hex maps the hex escape letters to their digit count.
+func unescape(s string, escapes map[byte]string, hex map[byte]int) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			return "", fmt.Errorf("trailing backslash")
+		}
+		c := s[i+1]
+		if text, ok := escapes[c]; ok {
+			b.WriteString(text)
+			i++
+			continue
+		}
+		n, ok := hex[c]
+		if !ok {
+			return "", fmt.Errorf("unknown escape \\%c", c)
+		}
+		if i+2+n > len(s) {
+			return "", fmt.Errorf("short escape \\%c", c)
+		}
+		r, err := strconv.ParseUint(s[i+2:i+2+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return "", fmt.Errorf("bad escape %s", s[i:i+2+n])
+		}
+		b.WriteRune(rune(r))
+		i += 1 + n
+	}
+	return b.String(), nil
+}
+
+// quotedEnd returns the index of the closing quote of a quoted scalar at s[0].
+func quotedEnd(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// splitFlow splits flow collection content on top-level commas: commas in
+// strings and in nested collections do not split.
+func splitFlow(s string, num int) ([]string, error) {
+	var out []string
+	start, depth := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			end := quotedEnd(s[i:])
+			if end < 0 {
+				return nil, fmt.Errorf("yaml line %d: unterminated string", num)
+			}
+			i += end
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		out = append(out, last)
+	}
+	return out, nil
+}
+
+// stripYAMLComment removes a trailing "# ..." outside of quotes.
+func stripYAMLComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			if end := quotedEnd(s[i:]); end > 0 {
+				i += end
+			}
+		case '#':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '\t' {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
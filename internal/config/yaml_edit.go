@@ -0,0 +1,438 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+func (yamlCodec) patcher() patcher { return yamlPatcher{} }
+
+// yamlPatcher edits block-style YAML line by line. Entries are found by
+// indentation; lines outside the edited entries, comments included, are
+// kept verbatim. Flow mappings other than {} are left to a full rewrite.
+type yamlPatcher struct{}
+
+type yamlDoc struct {
+	lines []string // with their "\n"
+}
+
+// yamlEntry is a "key: ..." line together with its nested block.
+type yamlEntry struct {
+	key    string
+	line   int
+	end    int // last content line + 1
+	indent int
+}
+
+func parseYAMLDoc(src []byte) *yamlDoc {
+	lines := strings.SplitAfter(string(src), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return &yamlDoc{lines: lines}
+}
+
+func (d *yamlDoc) bytes() []byte { return []byte(strings.Join(d.lines, "")) }
+
+// content returns the indentation and text of line i without its comment;
+// ok is false for blank, comment-only and document marker lines.
+func (d *yamlDoc) content(i int) (indent int, text string, ok bool) {
+	raw := strings.TrimRight(d.lines[i], "\r\n")
+	if strings.HasPrefix(raw, "---") || strings.HasPrefix(raw, "...") {
+		return 0, "", false
+	}
+	s := stripYAMLComment(raw)
+	text = strings.TrimSpace(s)
+	if text == "" {
+		return 0, "", false
+	}
+	return len(s) - len(strings.TrimLeft(s, " ")), text, true
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// entries lists the mapping entries at exactly indent within lines [from, to).
+func (d *yamlDoc) entries(from, to, indent int) ([]yamlEntry, error) {
+	var out []yamlEntry
+	for i := from; i < to; i++ {
+		ind, text, ok := d.content(i)
+		if !ok {
+			continue
+		}
+		if ind == indent && !isSeqItem(text) {
+			key, _, err := splitYAMLKey(text)
+			if err != nil {
+				return nil, errUnsupported
+			}
+			out = append(out, yamlEntry{key: key, line: i, end: i + 1, indent: ind})
+			continue
+		}
+		if ind < indent || len(out) == 0 {
+			return nil, errUnsupported
+		}
+		out[len(out)-1].end = i + 1
+	}
+	return out, nil
+}
+
+func (d *yamlDoc) root() ([]yamlEntry, error) {
+	for i := range d.lines {
+		if ind, _, ok := d.content(i); ok {
+			return d.entries(0, len(d.lines), ind)
+		}
+	}
+	return nil, nil
+}
+
+func findYAMLEntry(entries []yamlEntry, key string) int {
+	for i, e := range entries {
+		if e.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// inline returns the value written on the entry's own line.
+func (d *yamlDoc) inline(e yamlEntry) string {
+	_, text, _ := d.content(e.line)
+	_, rest, _ := splitYAMLKey(text)
+	return rest
+}
+
+// children returns the entries of a mapping-valued entry and their indent
+// (-1 when the mapping is empty: "key:" or "key: {}").
+func (d *yamlDoc) children(e yamlEntry) ([]yamlEntry, int, error) {
+	switch d.inline(e) {
+	case "":
+	case "{}":
+		return nil, -1, nil
+	default:
+		return nil, 0, errUnsupported
+	}
+	for i := e.line + 1; i < e.end; i++ {
+		if ind, _, ok := d.content(i); ok {
+			entries, err := d.entries(i, e.end, ind)
+			return entries, ind, err
+		}
+	}
+	return nil, -1, nil
+}
+
+// splitLine splits line i into indentation, "key:"-text, the blank run
+// before a comment, the comment and the line ending.
+func (d *yamlDoc) splitLine(i int) (indent, text, gap, comment, nl string) {
+	raw := d.lines[i]
+	body := strings.TrimRight(raw, "\r\n")
+	nl = raw[len(body):]
+	s := stripYAMLComment(body)
+	comment = body[len(s):]
+	t := strings.TrimRight(s, " \t")
+	if comment != "" {
+		gap = s[len(t):]
+	}
+	text = strings.TrimLeft(t, " ")
+	indent = t[:len(t)-len(text)]
+	return indent, text, gap, comment, nl
+}
+
+// yamlKeyEnd returns the index of the ':' ending the key in text.
+func yamlKeyEnd(text string) int {
+	if isYAMLQuoted(text) {
+		return quotedEnd(text) + 1
+	}
+	if i := strings.Index(text, ": "); i >= 0 {
+		return i
+	}
+	return len(text) - 1
+}
+
+// setInline rewrites the value on an entry line, keeping key and comment.
+func (d *yamlDoc) setInline(i int, value string) {
+	indent, text, gap, comment, nl := d.splitLine(i)
+	line := indent + text[:yamlKeyEnd(text)+1]
+	if value != "" {
+		line += " " + value
+	}
+	d.lines[i] = line + gap + comment + nl
+}
+
+func (d *yamlDoc) renameKey(i int, key string) {
+	indent, text, gap, comment, nl := d.splitLine(i)
+	d.lines[i] = indent + yamlKey(key) + text[yamlKeyEnd(text):] + gap + comment + nl
+}
+
+func (d *yamlDoc) insert(at int, lines ...string) {
+	if at > 0 && !strings.HasSuffix(d.lines[at-1], "\n") {
+		d.lines[at-1] += "\n"
+	}
+	d.lines = append(d.lines[:at], append(lines, d.lines[at:]...)...)
+}
+
+func (d *yamlDoc) remove(from, to int) {
+	d.lines = append(d.lines[:from], d.lines[to:]...)
+}
+
+// step guesses the indentation width used by the file.
+func (d *yamlDoc) step(e yamlEntry) int {
+	if _, ind, err := d.children(e); err == nil && ind > e.indent {
+		return ind - e.indent
+	}
+	return 2
+}
+
+// insertEntry appends "key: value" to the mapping of parent.
+func (d *yamlDoc) insertEntry(parent yamlEntry, key, value string, step int) error {
+	children, ind, err := d.children(parent)
+	if err != nil {
+		return err
+	}
+	line := yamlKey(key) + ": " + value + "\n"
+	if len(children) == 0 {
+		d.setInline(parent.line, "")
+		d.insert(parent.line+1, strings.Repeat(" ", parent.indent+step)+line)
+		return nil
+	}
+	d.insert(children[len(children)-1].end, strings.Repeat(" ", ind)+line)
+	return nil
+}
+
+// removeEntry deletes the entry key from the mapping of parent.
+func (d *yamlDoc) removeEntry(parent yamlEntry, key string) error {
+	children, _, err := d.children(parent)
+	if err != nil {
+		return err
+	}
+	i := findYAMLEntry(children, key)
+	if i < 0 {
+		return errUnsupported
+	}
+	d.remove(children[i].line, children[i].end)
+	if len(children) == 1 {
+		d.setInline(parent.line, "{}")
+	}
+	return nil
+}
+
+// yamlKey writes simple keys plain and quotes the rest, including words
+// other YAML readers would take for booleans or null.
+func yamlKey(k string) string {
+	switch strings.ToLower(k) {
+	case "", "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~":
+		return jsonString(k)
+	}
+	for i, r := range k {
+		ok := r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') ||
+			(i > 0 && (r == '-' || r == '.' || r == '/' || ('0' <= r && r <= '9')))
+		if !ok {
+			return jsonString(k)
+		}
+	}
+	return k
+}
+
+// top returns the document and the root entry named key.
+func (yamlPatcher) top(src []byte, key string) (*yamlDoc, *yamlEntry, error) {
+	d := parseYAMLDoc(src)
+	root, err := d.root()
+	if err != nil {
+		return nil, nil, err
+	}
+	i := findYAMLEntry(root, key)
+	if i < 0 {
+		return d, nil, nil
+	}
+	return d, &root[i], nil
+}
+
+func (p yamlPatcher) profile(src []byte, name string) (*yamlDoc, yamlEntry, int, error) {
+	d, profiles, err := p.top(src, "profiles")
+	if err != nil {
+		return nil, yamlEntry{}, 0, err
+	}
+	if profiles == nil {
+		return nil, yamlEntry{}, 0, errUnsupported
+	}
+	children, _, err := d.children(*profiles)
+	if err != nil {
+		return nil, yamlEntry{}, 0, err
+	}
+	i := findYAMLEntry(children, name)
+	if i < 0 {
+		return nil, yamlEntry{}, 0, errUnsupported
+	}
+	return d, children[i], d.step(*profiles), nil
+}
+
+func (p yamlPatcher) setVar(src []byte, profile, key, value string) ([]byte, error) {
+	d, prof, step, err := p.profile(src, profile)
+	if err != nil {
+		return nil, err
+	}
+	vars, _, err := d.children(prof)
+	if err != nil {
+		return nil, err
+	}
+	if i := findYAMLEntry(vars, key); i >= 0 {
+		if vars[i].end != vars[i].line+1 {
+			return nil, errUnsupported
+		}
+		d.setInline(vars[i].line, jsonString(value))
+		return d.bytes(), nil
+	}
+	if err := d.insertEntry(prof, key, jsonString(value), step); err != nil {
+		return nil, err
+	}
+	return d.bytes(), nil
+}
+
+func (p yamlPatcher) unsetVar(src []byte, profile, key string) ([]byte, error) {
+	d, prof, _, err := p.profile(src, profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.removeEntry(prof, key); err != nil {
+		return nil, err
+	}
+	return d.bytes(), nil
+}
+
+func (p yamlPatcher) addProfile(src []byte, name string) ([]byte, error) {
+	d, profiles, err := p.top(src, "profiles")
+	if err != nil {
+		return nil, err
+	}
+	if profiles == nil {
+		return nil, errUnsupported
+	}
+	if err := d.insertEntry(*profiles, name, "{}", d.step(*profiles)); err != nil {
+		return nil, err
+	}
+	return d.bytes(), nil
+}
+
+func (p yamlPatcher) removeProfile(src []byte, name string) ([]byte, error) {
+	d, profiles, err := p.top(src, "profiles")
+	if err != nil {
+		return nil, err
+	}
+	if profiles == nil {
+		return nil, errUnsupported
+	}
+	if err := d.removeEntry(*profiles, name); err != nil {
+		return nil, err
+	}
+	return d.bytes(), nil
+}
+
+func (p yamlPatcher) renameProfile(src []byte, oldName, newName string) ([]byte, error) {
+	d, prof, _, err := p.profile(src, oldName)
+	if err != nil {
+		return nil, err
+	}
+	d.renameKey(prof.line, newName)
+
+	root, err := d.root()
+	if err != nil {
+		return nil, err
+	}
+	if i := findYAMLEntry(root, "extends"); i >= 0 {
+		children, _, err := d.children(root[i])
+		if err != nil {
+			return nil, err
+		}
+		if j := findYAMLEntry(children, oldName); j >= 0 {
+			d.renameKey(children[j].line, newName)
+		}
+	}
+	return d.bytes(), nil
+}
+
+func (p yamlPatcher) setExtends(src []byte, name string, parents []string) ([]byte, error) {
+	d, ext, err := p.top(src, "extends")
+	if err != nil {
+		return nil, err
+	}
+	if ext == nil {
+		if len(parents) == 0 {
+			return src, nil
+		}
+		root, _ := d.root()
+		indent, step := 0, 2
+		if len(root) > 0 {
+			indent = root[0].indent
+			step = d.step(root[0])
+		}
+		pad := strings.Repeat(" ", indent)
+		d.insert(len(d.lines),
+			pad+"extends:\n",
+			pad+strings.Repeat(" ", step)+yamlKey(name)+": "+jsonList(parents)+"\n")
+		return d.bytes(), nil
+	}
+
+	children, _, err := d.children(*ext)
+	if err != nil {
+		return nil, err
+	}
+	i := findYAMLEntry(children, name)
+	switch {
+	case len(parents) == 0 && i < 0:
+		return src, nil
+	case len(parents) == 0:
+		if err := d.removeEntry(*ext, name); err != nil {
+			return nil, err
+		}
+	case i < 0:
+		if err := d.insertEntry(*ext, name, jsonList(parents), d.step(*ext)); err != nil {
+			return nil, err
+		}
+	default:
+		e := children[i]
+		if e.end == e.line+1 {
+			d.setInline(e.line, jsonList(parents))
+			break
+		}
+		// block sequence: rewrite the items, keeping their indentation
+		var items []string
+		indent := -1
+		for j := e.line + 1; j < e.end; j++ {
+			ind, text, ok := d.content(j)
+			if !ok {
+				continue
+			}
+			if !isSeqItem(text) {
+				return nil, errUnsupported
+			}
+			if indent < 0 {
+				indent = ind
+			}
+		}
+		for _, p := range parents {
+			items = append(items, strings.Repeat(" ", indent)+"- "+jsonString(p)+"\n")
+		}
+		d.remove(e.line+1, e.end)
+		d.insert(e.line+1, items...)
+	}
+	return d.bytes(), nil
+}
+
+func (yamlPatcher) setVersion(src []byte, version int) ([]byte, error) {
+	d := parseYAMLDoc(src)
+	root, err := d.root()
+	if err != nil {
+		return nil, err
+	}
+	v := strconv.Itoa(version)
+	if i := findYAMLEntry(root, "version"); i >= 0 {
+		d.setInline(root[i].line, v)
+		return d.bytes(), nil
+	}
+	if len(root) == 0 {
+		d.insert(len(d.lines), "version: "+v+"\n")
+		return d.bytes(), nil
+	}
+	d.insert(commentBlockStart(d.lines, root[0].line), strings.Repeat(" ", root[0].indent)+"version: "+v+"\n")
+	return d.bytes(), nil
+}
@@ -0,0 +1,43 @@
+package diffx
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := `--- old
++++ new
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	if got := Unified("old", "new", a, b); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if got := Unified("old", "new", a, a); got != "" {
+		t.Fatalf("equal inputs gave a diff:\n%s", got)
+	}
+}
+
+func TestUnified_EmptyAndNoNewline(t *testing.T) {
+	want := `--- old
++++ new
+@@ -0,0 +1,2 @@
++a
++b
+\ No newline at end of file
+`
+	if got := Unified("old", "new", "", "a\nb"); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
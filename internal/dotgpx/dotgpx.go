@@ -0,0 +1,111 @@
+// Package dotgpx reads directory-bound profiles from .gpx files.
+//
+// A .gpx file contains an optional profile name on its own line and any
+// number of KEY=VALUE lines that override the profile. Blank lines and
+// lines starting with # are ignored:
+//
+//	# pin this repository to the corp proxy
+//	corp
+//	GOFLAGS=-mod=mod
+package dotgpx
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZeraiGR/gpx/internal/envx"
+)
+
+const FileName = ".gpx"
+
+type File struct {
+	Path    string
+	Sum     string // sha256 of the content, used to trust a specific version
+	Profile string
+	Vars    envx.Vars
+}
+
+// Find walks up from dir and returns the path of the nearest .gpx file,
+// or "" if there is none.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("abs %s: %w", dir, err)
+	}
+	for {
+		p := filepath.Join(dir, FileName)
+		if st, err := os.Stat(p); err == nil && st.Mode().IsRegular() {
+			return p, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("stat %s: %w", p, err)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads and parses a .gpx file.
+func Load(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	f, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	f.Path = path
+	return f, nil
+}
+
+// Parse parses .gpx content. Path is left empty.
+func Parse(b []byte) (*File, error) {
+	f := &File{Sum: Sum(b)}
+	var tokens []string
+
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	n := 0
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.Contains(line, "=") {
+			tokens = append(tokens, line)
+			continue
+		}
+		if f.Profile != "" {
+			return nil, fmt.Errorf("line %d: second profile name %q (already %q)", n, line, f.Profile)
+		}
+		f.Profile = line
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	vars, err := envx.ParseAssignments(tokens)
+	if err != nil {
+		return nil, err
+	}
+	f.Vars = vars
+	if f.Profile == "" && len(f.Vars) == 0 {
+		return nil, fmt.Errorf("neither profile name nor variables")
+	}
+	return f, nil
+}
+
+// Sum returns the hex sha256 of content.
+func Sum(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
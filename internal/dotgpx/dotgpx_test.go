@@ -0,0 +1,51 @@
+package dotgpx
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ZeraiGR/gpx/internal/envx"
+)
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte("# comment\n\ncorp\ngoflags=-mod=mod\n"))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if f.Profile != "corp" {
+		t.Fatalf("Profile = %q, want corp", f.Profile)
+	}
+	if want := (envx.Vars{"GOFLAGS": "-mod=mod"}); !reflect.DeepEqual(f.Vars, want) {
+		t.Fatalf("Vars = %+v, want %+v", f.Vars, want)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, in := range []string{"", "# only comment\n", "a\nb\n", "1BAD=x\n"} {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Fatalf("Parse(%q): expected error", in)
+		}
+	}
+}
+
+func TestFind_WalksUp(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(root, FileName)
+	if err := os.WriteFile(want, []byte("corp\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Find(sub)
+	if err != nil {
+		t.Fatalf("Find error: %v", err)
+	}
+	if got != want {
+		t.Fatalf("Find = %q, want %q", got, want)
+	}
+}
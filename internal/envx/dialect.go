@@ -0,0 +1,122 @@
+package envx
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect renders env var assignments in the syntax of a particular shell.
+// Keys are expected to be validated by the caller.
+type Dialect interface {
+	Name() string
+	Export(key, value string) string
+	Unset(key string) string
+	// ParseExport is the inverse of Export. It reports false for lines
+	// that are not a literal assignment in this dialect.
+	ParseExport(line string) (key, value string, ok bool)
+}
+
+var (
+	// POSIX covers sh, bash and zsh.
+	POSIX      Dialect = posixDialect{}
+	Fish       Dialect = fishDialect{}
+	PowerShell Dialect = pwshDialect{}
+	Nu         Dialect = nuDialect{}
+)
+
+// DialectFor maps a shell name to its dialect. Empty name means POSIX.
+func DialectFor(shell string) (Dialect, error) {
+	switch shell {
+	case "", "sh", "bash", "zsh":
+		return POSIX, nil
+	case "fish":
+		return Fish, nil
+	case "pwsh", "powershell":
+		return PowerShell, nil
+	case "nu", "nushell":
+		return Nu, nil
+	default:
+		return nil, fmt.Errorf("unsupported shell %q (expected sh, bash, zsh, fish, pwsh or nu)", shell)
+	}
+}
+
+type posixDialect struct{}
+
+func (posixDialect) Name() string { return "posix" }
+
+func (posixDialect) Export(key, value string) string {
+	return fmt.Sprintf(`export %s=%s`, key, QuoteForShell(value))
+}
+
+func (posixDialect) Unset(key string) string {
+	return fmt.Sprintf("unset %s", key)
+}
+
+type fishDialect struct{}
+
+func (fishDialect) Name() string { return "fish" }
+
+func (fishDialect) Export(key, value string) string {
+	return fmt.Sprintf(`set -gx %s %s`, key, QuoteForFish(value))
+}
+
+func (fishDialect) Unset(key string) string {
+	return fmt.Sprintf("set -e %s", key)
+}
+
+// QuoteForFish quotes value for fish using single quotes.
+// Inside them fish only treats \' and \\ specially.
+// a'b -> 'a\'b'
+// a\b -> 'a\\b'
+func QuoteForFish(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+type pwshDialect struct{}
+
+func (pwshDialect) Name() string { return "pwsh" }
+
+func (pwshDialect) Export(key, value string) string {
+	return fmt.Sprintf(`$env:%s = %s`, key, QuoteForPowerShell(value))
+}
+
+func (pwshDialect) Unset(key string) string {
+	return fmt.Sprintf("Remove-Item Env:%s -ErrorAction SilentlyContinue", key)
+}
+
+// QuoteForPowerShell quotes value as a verbatim (single-quoted) string,
+// doubling embedded single quotes:
+//
+//	a'b -> 'a''b'
+func QuoteForPowerShell(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+type nuDialect struct{}
+
+func (nuDialect) Name() string { return "nu" }
+
+func (nuDialect) Export(key, value string) string {
+	return fmt.Sprintf(`$env.%s = %s`, key, QuoteForNu(value))
+}
+
+func (nuDialect) Unset(key string) string {
+	return fmt.Sprintf("hide-env -i %s", key)
+}
+
+var nuEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+)
+
+// QuoteForNu quotes value as a nushell double-quoted string,
+// escaping backslashes, quotes and control characters.
+// a"b -> "a\"b"
+func QuoteForNu(s string) string {
+	return `"` + nuEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,105 @@
+package envx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuoteForFish(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "''"},
+		{"abc", "'abc'"},
+		{"a'b", `'a\'b'`},
+		{`a\b`, `'a\\b'`},
+		{"$HOME", "'$HOME'"},
+	}
+
+	for _, tt := range tests {
+		if got := QuoteForFish(tt.in); got != tt.want {
+			t.Fatalf("QuoteForFish(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExportAndUnsetLines_Fish(t *testing.T) {
+	lines, err := Vars{"GOPROXY": "off", "GOFLAGS": "-mod=mod"}.ExportLinesFor(Fish)
+	if err != nil {
+		t.Fatalf("ExportLinesFor error: %v", err)
+	}
+	want := []string{
+		"set -gx GOFLAGS '-mod=mod'",
+		"set -gx GOPROXY 'off'",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("ExportLinesFor got %#v, want %#v", lines, want)
+	}
+
+	lines, err = UnsetLinesFor(Fish, []string{"goproxy"})
+	if err != nil {
+		t.Fatalf("UnsetLinesFor error: %v", err)
+	}
+	if want := []string{"set -e GOPROXY"}; !reflect.DeepEqual(lines, want) {
+		t.Fatalf("UnsetLinesFor got %#v, want %#v", lines, want)
+	}
+}
+
+func TestQuoteForPowerShell(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "''"},
+		{"a'b", "'a''b'"},
+		{"$env:HOME", "'$env:HOME'"},
+	}
+
+	for _, tt := range tests {
+		if got := QuoteForPowerShell(tt.in); got != tt.want {
+			t.Fatalf("QuoteForPowerShell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteForNu(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", `""`},
+		{`a"b`, `"a\"b"`},
+		{`a\b`, `"a\\b"`},
+		{"a\nb", `"a\nb"`},
+	}
+
+	for _, tt := range tests {
+		if got := QuoteForNu(tt.in); got != tt.want {
+			t.Fatalf("QuoteForNu(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseExport_RoundTrip(t *testing.T) {
+	values := []string{"", "abc", "a b", "a'b", `a\b`, `a"b`, "$HOME", "https://proxy.golang.org,direct"}
+	for _, d := range []Dialect{POSIX, Fish, PowerShell, Nu} {
+		for _, v := range values {
+			line := d.Export("GOPROXY", v)
+			k, got, ok := d.ParseExport(line)
+			if !ok || k != "GOPROXY" || got != v {
+				t.Fatalf("%s: ParseExport(%q) = %q, %q, %v; want GOPROXY, %q", d.Name(), line, k, got, ok, v)
+			}
+		}
+	}
+}
+
+func TestParseExport_POSIXHandWritten(t *testing.T) {
+	k, v, ok := POSIX.ParseExport(`export GOPRIVATE="github.com/acme/*" # corp`)
+	if !ok || k != "GOPRIVATE" || v != "github.com/acme/*" {
+		t.Fatalf("ParseExport got %q, %q, %v", k, v, ok)
+	}
+	if _, _, ok := POSIX.ParseExport("alias ll='ls -la'"); ok {
+		t.Fatalf("expected alias line to be rejected")
+	}
+}
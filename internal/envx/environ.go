@@ -0,0 +1,35 @@
+package envx
+
+import (
+	"strings"
+)
+
+// Overlay applies vars on top of an environ-style list (KEY=VALUE entries),
+// removing keys listed in unset. Entries for overridden keys are dropped
+// so that every key appears at most once. Result keeps the original order,
+// with new keys appended sorted.
+func Overlay(environ []string, vars Vars, unset []string) []string {
+	drop := make(map[string]struct{}, len(unset)+len(vars))
+	for _, k := range unset {
+		drop[k] = struct{}{}
+	}
+	for k := range vars {
+		drop[k] = struct{}{}
+	}
+
+	out := make([]string, 0, len(environ)+len(vars))
+	for _, kv := range environ {
+		k := kv
+		if eq := strings.IndexByte(kv, '='); eq >= 0 {
+			k = kv[:eq]
+		}
+		if _, ok := drop[k]; ok {
+			continue
+		}
+		out = append(out, kv)
+	}
+	for _, k := range vars.KeysSorted() {
+		out = append(out, k+"="+vars[k])
+	}
+	return out
+}
@@ -40,13 +40,18 @@ func (v Vars) KeysSorted() []string {
 // ExportLines returns shell-safe export lines, sorted by key.
 // Empty values are exported as empty string: export KEY=""
 func (v Vars) ExportLines() ([]string, error) {
+	return v.ExportLinesFor(POSIX)
+}
+
+// ExportLinesFor is like ExportLines but renders in the given shell dialect.
+func (v Vars) ExportLinesFor(d Dialect) ([]string, error) {
 	keys := v.KeysSorted()
 	out := make([]string, 0, len(keys))
 	for _, k := range keys {
 		if err := ValidateKey(k); err != nil {
 			return nil, err
 		}
-		out = append(out, fmt.Sprintf(`export %s=%s`, k, QuoteForShell(v[k])))
+		out = append(out, d.Export(k, v[k]))
 	}
 	return out, nil
 }
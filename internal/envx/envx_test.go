@@ -60,3 +60,23 @@ func TestExportLines_Sorted(t *testing.T) {
 		t.Fatalf("ExportLines got %#v, want %#v", lines, want)
 	}
 }
+
+func TestOverlay(t *testing.T) {
+	environ := []string{
+		"PATH=/usr/bin",
+		"GOPROXY=https://proxy.corp.local",
+		"GOFLAGS=-mod=mod",
+	}
+	got := Overlay(environ, Vars{
+		"GOPROXY":     "off",
+		"GOTOOLCHAIN": "local",
+	}, []string{"GOFLAGS"})
+	want := []string{
+		"PATH=/usr/bin",
+		"GOPROXY=off",
+		"GOTOOLCHAIN=local",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Overlay got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package envx
+
+import (
+	"strings"
+)
+
+func (posixDialect) ParseExport(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "export ")
+	key, raw, ok := strings.Cut(line, "=")
+	if !ok || ValidateKey(key) != nil {
+		return "", "", false
+	}
+	v, ok := unquotePOSIX(raw)
+	return key, v, ok
+}
+
+func (fishDialect) ParseExport(line string) (string, string, bool) {
+	fields := strings.SplitN(strings.TrimSpace(line), " ", 4)
+	if len(fields) != 4 || fields[0] != "set" || fields[1] != "-gx" || ValidateKey(fields[2]) != nil {
+		return "", "", false
+	}
+	v, ok := unquoteFish(fields[3])
+	return fields[2], v, ok
+}
+
+func (pwshDialect) ParseExport(line string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "$env:")
+	if !ok {
+		return "", "", false
+	}
+	key, raw, ok := strings.Cut(rest, "=")
+	key = strings.TrimSpace(key)
+	raw = strings.TrimSpace(raw)
+	if !ok || ValidateKey(key) != nil || len(raw) < 2 || raw[0] != '\'' || raw[len(raw)-1] != '\'' {
+		return "", "", false
+	}
+	inner := raw[1 : len(raw)-1]
+	if strings.Count(inner, "'")%2 != 0 {
+		return "", "", false
+	}
+	return key, strings.ReplaceAll(inner, "''", "'"), true
+}
+
+func (nuDialect) ParseExport(line string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "$env.")
+	if !ok {
+		return "", "", false
+	}
+	key, raw, ok := strings.Cut(rest, "=")
+	key = strings.TrimSpace(key)
+	raw = strings.TrimSpace(raw)
+	if !ok || ValidateKey(key) != nil || len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return "", "", false
+	}
+	return key, unescapeBackslash(raw[1:len(raw)-1], map[byte]string{'n': "\n", 'r': "\r", 't': "\t"}), true
+}
+
+// unquotePOSIX handles a word made of bare, '...' and "..." segments,
+// which covers everything QuoteForShell produces. Expansions are not evaluated.
+func unquotePOSIX(s string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return "", false
+			}
+			b.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) && strings.IndexByte("$`\"\\", s[j+1]) >= 0 {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return "", false
+			}
+			i = j
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case ' ', '\t', ';', '#':
+			// end of the word: trailing comment or next command
+			return b.String(), true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), true
+}
+
+// unquoteFish handles the single-quoted form produced by QuoteForFish
+// as well as bare words.
+func unquoteFish(s string) (string, bool) {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return unescapeBackslash(s[1:len(s)-1], nil), true
+	}
+	if strings.ContainsAny(s, `'"$ `) {
+		return "", false
+	}
+	return s, true
+}
+
+// unescapeBackslash drops backslashes before any character, mapping
+// the ones listed in special (e.g. n -> newline).
+func unescapeBackslash(s string, special map[byte]string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if r, ok := special[s[i]]; ok {
+				b.WriteString(r)
+			} else {
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package envx
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Snapshot holds original values of env keys.
+// A nil value means the key was not set at all (as opposed to set to "").
+type Snapshot map[string]*string
+
+// Capture records current values of keys from the process environment.
+// Keys already present in s are kept as is, so the earliest value wins.
+func (s Snapshot) Capture(keys []string) {
+	for _, k := range keys {
+		if _, ok := s[k]; ok {
+			continue
+		}
+		if v, ok := os.LookupEnv(k); ok {
+			s[k] = &v
+		} else {
+			s[k] = nil
+		}
+	}
+}
+
+// Encode serializes the snapshot into a single shell-safe token.
+func (s Snapshot) Encode() (string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("marshal snapshot: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeSnapshot is the inverse of Snapshot.Encode.
+func DecodeSnapshot(enc string) (Snapshot, error) {
+	b, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return nil, fmt.Errorf("decode snapshot: %w", err)
+	}
+	s := Snapshot{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("parse snapshot: %w", err)
+	}
+	return s, nil
+}
+
+// RestoreLines returns unset lines for keys that were not set
+// followed by export lines for keys that had a value.
+func (s Snapshot) RestoreLines(d Dialect) ([]string, error) {
+	vars := Vars{}
+	var unset []string
+	for k, v := range s {
+		if v == nil {
+			unset = append(unset, k)
+		} else {
+			vars[k] = *v
+		}
+	}
+	sort.Strings(unset)
+
+	var out []string
+	if len(unset) > 0 {
+		lines, err := UnsetLinesFor(d, unset)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, lines...)
+	}
+	lines, err := vars.ExportLinesFor(d)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, lines...), nil
+}
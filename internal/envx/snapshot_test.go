@@ -0,0 +1,49 @@
+package envx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnapshot_RoundTripAndRestore(t *testing.T) {
+	t.Setenv("GPX_TEST_SET", "a'b")
+	t.Setenv("GPX_TEST_EMPTY", "")
+
+	s := Snapshot{}
+	s.Capture([]string{"GPX_TEST_SET", "GPX_TEST_EMPTY", "GPX_TEST_MISSING"})
+
+	enc, err := s.Encode()
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	got, err := DecodeSnapshot(enc)
+	if err != nil {
+		t.Fatalf("DecodeSnapshot error: %v", err)
+	}
+
+	lines, err := got.RestoreLines(POSIX)
+	if err != nil {
+		t.Fatalf("RestoreLines error: %v", err)
+	}
+	want := []string{
+		"unset GPX_TEST_MISSING",
+		"export GPX_TEST_EMPTY=''",
+		`export GPX_TEST_SET='a'"'"'b'`,
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("RestoreLines got %#v, want %#v", lines, want)
+	}
+}
+
+func TestSnapshot_EarliestValueWins(t *testing.T) {
+	t.Setenv("GPX_TEST_KEY", "original")
+	s := Snapshot{}
+	s.Capture([]string{"GPX_TEST_KEY"})
+
+	t.Setenv("GPX_TEST_KEY", "from-profile")
+	s.Capture([]string{"GPX_TEST_KEY"})
+
+	if v := s["GPX_TEST_KEY"]; v == nil || *v != "original" {
+		t.Fatalf("expected original value to be kept, got %v", v)
+	}
+}
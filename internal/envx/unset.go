@@ -7,6 +7,12 @@ import (
 )
 
 func UnsetLines(keys []string) ([]string, error) {
+	return UnsetLinesFor(POSIX, keys)
+}
+
+// UnsetLinesFor normalizes and validates keys and renders sorted
+// unset lines in the given shell dialect.
+func UnsetLinesFor(d Dialect, keys []string) ([]string, error) {
 	if len(keys) == 0 {
 		return nil, fmt.Errorf("no keys provided")
 	}
@@ -24,7 +30,7 @@ func UnsetLines(keys []string) ([]string, error) {
 
 	out := make([]string, 0, len(normalized))
 	for _, k := range normalized {
-		out = append(out, fmt.Sprintf("unset %s", k))
+		out = append(out, d.Unset(k))
 	}
 	return out, nil
 }
@@ -0,0 +1,137 @@
+package fsx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteFile_ReplacesWithoutLeftovers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	for _, content := range []string{"one", "two"} {
+		if err := WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := os.ReadFile(path); string(b) != content {
+			t.Fatalf("content = %q, want %q", b, content)
+		}
+	}
+	if st, _ := os.Stat(path); st.Mode().Perm() != 0o600 {
+		t.Fatalf("perm = %v, want 0600", st.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("dir has %d entries, want only the file", len(entries))
+	}
+}
+
+func TestLock_TimesOutWhileHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	l, err := Lock(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Lock(path, 100*time.Millisecond)
+	var lerr *LockedError
+	if !errors.As(err, &lerr) {
+		t.Fatalf("second Lock error = %v, want LockedError", err)
+	}
+
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	l, err = Lock(path, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Lock after Unlock: %v", err)
+	}
+	_ = l.Unlock()
+}
+
+func TestLock_SerializesReadModifyWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	if err := WriteFile(path, []byte("0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l, err := Lock(path, 10*time.Second)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer l.Unlock()
+			b, _ := os.ReadFile(path)
+			v, _ := strconv.Atoi(string(b))
+			if err := WriteFile(path, []byte(strconv.Itoa(v+1)), 0o644); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if b, _ := os.ReadFile(path); string(b) != strconv.Itoa(n) {
+		t.Fatalf("counter = %s, want %d", b, n)
+	}
+}
+
+func TestReplaceFile_KeepsSymlinkAndMode(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "dotfiles", "zshrc")
+	if err := os.MkdirAll(filepath.Dir(real), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(real, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, ".zshrc")
+	if err := os.Symlink(filepath.Join("dotfiles", "zshrc"), link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	got, err := ReplaceFile(link, []byte("new"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != real {
+		t.Fatalf("wrote %s, want %s", got, real)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("link was replaced: %v, %v", fi.Mode(), err)
+	}
+	if b, _ := os.ReadFile(real); string(b) != "new" {
+		t.Fatalf("content = %q, want new", b)
+	}
+	if fi, _ := os.Stat(real); fi.Mode().Perm() != 0o600 {
+		t.Fatalf("perm = %v, want 0600 kept", fi.Mode().Perm())
+	}
+}
+
+func TestResolve_DanglingLink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "rc")
+	if err := os.Symlink("missing", link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if got, err := Resolve(link); err != nil || got != filepath.Join(dir, "missing") {
+		t.Fatalf("Resolve = %q, %v", got, err)
+	}
+
+	loop := filepath.Join(dir, "loop")
+	if err := os.Symlink("loop", loop); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve(loop); err == nil {
+		t.Fatal("Resolve accepted a symlink loop")
+	}
+}
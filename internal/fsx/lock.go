@@ -0,0 +1,62 @@
+package fsx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockedError is returned by Lock when the lock stays taken for the whole
+// timeout, normally because another gpx process is changing the same file.
+type LockedError struct {
+	Path    string // the lock file
+	Timeout time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("another gpx is running: %s is locked (waited %s)", e.Path, e.Timeout)
+}
+
+// errWouldBlock is returned by tryLock when the lock is taken.
+var errWouldBlock = errors.New("lock is taken")
+
+// retryInterval is how often Lock retries a taken lock.
+const retryInterval = 50 * time.Millisecond
+
+// FileLock is a held lock; Unlock releases it.
+type FileLock struct {
+	release func() error
+}
+
+// Lock takes the advisory lock of path, a lock file named path + ".lock",
+// waiting up to timeout for other holders. Where flock is available the
+// lock file is left in place after Unlock, as removing it would race with
+// the next holder.
+func Lock(path string, timeout time.Duration) (*FileLock, error) {
+	lockPath := path + ".lock"
+	dir := filepath.Dir(lockPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir %s: %w", dir, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		release, err := tryLock(lockPath)
+		if err == nil {
+			return &FileLock{release: release}, nil
+		}
+		if !errors.Is(err, errWouldBlock) {
+			return nil, fmt.Errorf("lock %s: %w", lockPath, err)
+		}
+		if time.Now().After(deadline) {
+			return nil, &LockedError{Path: lockPath, Timeout: timeout}
+		}
+		time.Sleep(retryInterval)
+	}
+}
+
+func (l *FileLock) Unlock() error {
+	return l.release()
+}
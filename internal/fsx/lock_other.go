@@ -0,0 +1,20 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package fsx
+
+import "os"
+
+// tryLock takes the lock by creating path exclusively. Unlike flock, the
+// lock file of a crashed process stays behind and has to be removed by hand;
+// the LockedError names it.
+func tryLock(path string) (func() error, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, errWouldBlock
+		}
+		return nil, err
+	}
+	_ = f.Close()
+	return func() error { return os.Remove(path) }, nil
+}
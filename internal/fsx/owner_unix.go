@@ -0,0 +1,22 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package fsx
+
+import (
+	"os"
+	"syscall"
+)
+
+func fileOwner(fi os.FileInfo) (uid, gid int, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
+
+// Writable reports whether this process may write to path (a file, or a
+// directory to create files in). It is false on a read-only file system.
+func Writable(path string) bool {
+	return syscall.Access(path, 0x2 /* W_OK */) == nil
+}
@@ -0,0 +1,80 @@
+package fsx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// maxLinks bounds symlink chains, as the kernel's ELOOP does.
+const maxLinks = 40
+
+// Resolve follows the symlinks at path to the file they point to; a path
+// that is not a link is returned as is. Unlike filepath.EvalSymlinks it
+// also follows a link whose target does not exist yet, so writing through
+// the result creates the target instead of replacing the link.
+func Resolve(path string) (string, error) {
+	p := path
+	for range maxLinks {
+		fi, err := os.Lstat(p)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return p, nil
+			}
+			return "", fmt.Errorf("stat %s: %w", p, err)
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return p, nil
+		}
+		t, err := os.Readlink(p)
+		if err != nil {
+			return "", fmt.Errorf("readlink %s: %w", p, err)
+		}
+		if !filepath.IsAbs(t) {
+			t = filepath.Join(filepath.Dir(p), t)
+		}
+		p = t
+	}
+	return "", fmt.Errorf("resolve %s: too many levels of symbolic links", path)
+}
+
+// ReplaceFile is WriteFile for files the user owns the layout of, such as
+// rc files: path is resolved (see Resolve) and the real file is replaced,
+// keeping a symlink at path intact. An existing file keeps its mode and
+// owner; perm is used for a new one. It returns the path written.
+func ReplaceFile(path string, b []byte, perm os.FileMode) (string, error) {
+	target, err := Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	var fix func(f *os.File) error
+	fi, err := os.Stat(target)
+	switch {
+	case err == nil:
+		perm = fi.Mode().Perm()
+		fix = func(f *os.File) error { return keepOwner(f, fi) }
+	case !errors.Is(err, os.ErrNotExist):
+		return "", fmt.Errorf("stat %s: %w", target, err)
+	}
+	return target, writeFile(target, b, perm, fix)
+}
+
+// keepOwner gives f the owner and group of fi where they differ.
+func keepOwner(f *os.File, fi os.FileInfo) error {
+	uid, gid, ok := fileOwner(fi)
+	if !ok {
+		return nil
+	}
+	cur, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", f.Name(), err)
+	}
+	if cuid, cgid, _ := fileOwner(cur); cuid == uid && cgid == gid {
+		return nil
+	}
+	if err := f.Chown(uid, gid); err != nil {
+		return fmt.Errorf("keep owner of %s: %w", fi.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Package fsx has the file primitives gpx uses to change files safely
+// next to other gpx processes: atomic replacement and advisory locks.
+package fsx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile atomically replaces path with b: the data goes to a uniquely
+// named temp file in the same directory, is synced to disk, and is renamed
+// over path. Readers see either the old or the new content, and two
+// writers never share a temp file. The directory must exist.
+func WriteFile(path string, b []byte, perm os.FileMode) error {
+	return writeFile(path, b, perm, nil)
+}
+
+// writeFile is WriteFile with a hook that adjusts the temp file (owner,
+// say) before it replaces path.
+func writeFile(path string, b []byte, perm os.FileMode, fix func(f *os.File) error) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	tmp := f.Name()
+	ok := false
+	defer func() {
+		if !ok {
+			_ = f.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	if _, err := f.Write(b); err != nil {
+		return fmt.Errorf("write %s: %w", tmp, err)
+	}
+	if err := f.Chmod(perm); err != nil {
+		return fmt.Errorf("chmod %s: %w", tmp, err)
+	}
+	if fix != nil {
+		if err := fix(f); err != nil {
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", tmp, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("rename %s: %w", tmp, err)
+	}
+	ok = true
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes a rename in dir durable. It is best-effort: some platforms
+// cannot open or sync directories.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
@@ -0,0 +1,209 @@
+// Package goenv edits the go command's own env file ($GOENV),
+// the one `go env -w` writes to.
+package goenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// knownKeys are the variables the go command reads from its env file.
+// GOROOT and GOENV itself cannot be set there.
+var knownKeys = map[string]struct{}{
+	"AR": {}, "CC": {}, "CXX": {}, "FC": {}, "GCCGO": {}, "PKG_CONFIG": {},
+	"CGO_ENABLED": {}, "CGO_CFLAGS": {}, "CGO_CPPFLAGS": {}, "CGO_CXXFLAGS": {},
+	"CGO_FFLAGS": {}, "CGO_LDFLAGS": {},
+	"GO111MODULE": {}, "GOAMD64": {}, "GOARCH": {}, "GOARM": {}, "GOARM64": {},
+	"GOAUTH": {}, "GOBIN": {}, "GOCACHE": {}, "GOCACHEPROG": {}, "GODEBUG": {},
+	"GOEXPERIMENT": {}, "GOFIPS140": {}, "GOFLAGS": {}, "GOINSECURE": {},
+	"GOMIPS": {}, "GOMIPS64": {}, "GOMODCACHE": {}, "GONOPROXY": {},
+	"GONOSUMDB": {}, "GOOS": {}, "GOPATH": {}, "GOPPC64": {}, "GOPRIVATE": {},
+	"GOPROXY": {}, "GORISCV64": {}, "GOSUMDB": {}, "GOTMPDIR": {},
+	"GOTOOLCHAIN": {}, "GOVCS": {}, "GOWASM": {}, "GO386": {},
+}
+
+// fallbackDefaults are the go.env defaults of current Go releases, used
+// when there is no go command to ask.
+var fallbackDefaults = map[string]string{
+	"GOPROXY":     "https://proxy.golang.org,direct",
+	"GOSUMDB":     "sum.golang.org",
+	"GOTOOLCHAIN": "auto",
+}
+
+// defaults asks the go command once for the values it uses when nothing is
+// set (see loadDefaults).
+var defaults = sync.OnceValue(func() map[string]string {
+	return loadDefaults("go")
+})
+
+// loadDefaults runs `go env -json` with the go env file disabled and every
+// known variable removed from the environment, outside of any module, so the
+// go command reports its defaults ($GOROOT/go.env included). Without a
+// usable go command it falls back to fallbackDefaults and the documented
+// GOPATH and GOCACHE locations.
+func loadDefaults(goBin string) map[string]string {
+	cmd := exec.Command(goBin, "env", "-json")
+	cmd.Dir = os.TempDir()
+	cmd.Env = []string{"GOENV=off"}
+	for _, kv := range os.Environ() {
+		k, _, _ := strings.Cut(kv, "=")
+		if !IsKnownKey(k) && k != "GOENV" {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	if b, err := cmd.Output(); err == nil {
+		out := map[string]string{}
+		if err := json.Unmarshal(b, &out); err == nil {
+			return out
+		}
+	}
+
+	out := map[string]string{}
+	for k, v := range fallbackDefaults {
+		out[k] = v
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		out["GOPATH"] = filepath.Join(home, "go")
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		out["GOCACHE"] = filepath.Join(dir, "go-build")
+	}
+	return out
+}
+
+// Default returns the value the go command uses for key when it is set
+// neither in the environment nor in the go env file ("" for most variables).
+// GONOPROXY and GONOSUMDB default to GOPRIVATE and GOMODCACHE to
+// GOPATH/pkg/mod; lookup returns the effective value of those.
+func Default(key string, lookup func(string) string) string {
+	switch key {
+	case "GONOPROXY", "GONOSUMDB":
+		return lookup("GOPRIVATE")
+	case "GOMODCACHE":
+		list := filepath.SplitList(lookup("GOPATH"))
+		if len(list) == 0 || list[0] == "" {
+			return ""
+		}
+		return filepath.Join(list[0], "pkg", "mod")
+	}
+	if !IsKnownKey(key) {
+		return ""
+	}
+	return defaults()[key]
+}
+
+// IsKnownKey reports whether key can be stored in the go env file.
+func IsKnownKey(key string) bool {
+	_, ok := knownKeys[key]
+	return ok
+}
+
+// Path returns the go env file location: $GOENV if set,
+// otherwise <os.UserConfigDir>/go/env, like the go command does.
+func Path() (string, error) {
+	if p := os.Getenv("GOENV"); p != "" {
+		if p == "off" {
+			return "", fmt.Errorf("GOENV=off: go env file is disabled")
+		}
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("get config dir: %w", err)
+	}
+	return filepath.Join(dir, "go", "env"), nil
+}
+
+// Read parses the go env file. A missing file reads as empty.
+func Read(path string) (map[string]string, error) {
+	out := map[string]string{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return out, nil
+		}
+		return nil, fmt.Errorf("read go env %s: %w", path, err)
+	}
+	for _, ln := range strings.Split(string(b), "\n") {
+		key, val, ok := strings.Cut(strings.TrimRight(ln, "\r"), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if _, seen := out[key]; !seen {
+			out[key] = val
+		}
+	}
+	return out, nil
+}
+
+// Validate rejects keys the go env file cannot hold and values
+// that do not fit on one line.
+func Validate(vars map[string]string) error {
+	var bad []string
+	for k, v := range vars {
+		if !IsKnownKey(k) {
+			bad = append(bad, k)
+			continue
+		}
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("value of %s contains a newline", k)
+		}
+	}
+	if len(bad) > 0 {
+		sort.Strings(bad)
+		return fmt.Errorf("not Go env variables, cannot be written to the go env file: %s", strings.Join(bad, ", "))
+	}
+	return nil
+}
+
+// Upsert rewrites KEY=VALUE lines of the given keys in go env file content,
+// leaving every other line untouched. Empty values remove the key so that
+// the go command falls back to its default; new keys are appended sorted.
+func Upsert(content string, vars map[string]string) string {
+	done := map[string]bool{}
+	var out []string
+
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+	for _, ln := range lines {
+		key, _, ok := strings.Cut(ln, "=")
+		key = strings.TrimSpace(key)
+		v, owned := vars[key]
+		if !ok || !owned {
+			out = append(out, ln)
+			continue
+		}
+		if done[key] || v == "" {
+			// drop duplicates and cleared keys
+			done[key] = true
+			continue
+		}
+		out = append(out, key+"="+v)
+		done[key] = true
+	}
+
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !done[k] && vars[k] != "" {
+			out = append(out, k+"="+vars[k])
+		}
+	}
+
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
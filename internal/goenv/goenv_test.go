@@ -0,0 +1,58 @@
+package goenv
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpsert(t *testing.T) {
+	old := "GOFLAGS=-mod=mod\nGOPROXY=https://old\nGOPRIVATE=corp.local/*\n"
+	got := Upsert(old, map[string]string{
+		"GOPROXY":     "https://proxy.golang.org,direct",
+		"GOPRIVATE":   "",
+		"GOTOOLCHAIN": "auto",
+	})
+	want := "GOFLAGS=-mod=mod\nGOPROXY=https://proxy.golang.org,direct\nGOTOOLCHAIN=auto\n"
+	if got != want {
+		t.Fatalf("Upsert got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestUpsert_Empty(t *testing.T) {
+	if got := Upsert("", map[string]string{"GOPROXY": "off"}); got != "GOPROXY=off\n" {
+		t.Fatalf("Upsert got %q", got)
+	}
+}
+
+func TestValidate_RejectsNonGoKeys(t *testing.T) {
+	err := Validate(map[string]string{"GOPROXY": "off", "HTTP_PROXY": "x", "GPX_PROFILE": "corp"})
+	if err == nil || !strings.Contains(err.Error(), "GPX_PROFILE, HTTP_PROXY") {
+		t.Fatalf("expected error listing non-Go keys, got %v", err)
+	}
+}
+
+func TestDefault_Derived(t *testing.T) {
+	eff := map[string]string{"GOPRIVATE": "corp.local/*", "GOPATH": "/w" + string(filepath.ListSeparator) + "/x"}
+	lookup := func(k string) string { return eff[k] }
+	for key, want := range map[string]string{
+		"GONOPROXY":  "corp.local/*",
+		"GONOSUMDB":  "corp.local/*",
+		"GOMODCACHE": filepath.Join("/w", "pkg", "mod"),
+		"HTTP_PROXY": "",
+	} {
+		if got := Default(key, lookup); got != want {
+			t.Errorf("Default(%s) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestLoadDefaults_NoGoCommand(t *testing.T) {
+	got := loadDefaults(filepath.Join(t.TempDir(), "no-such-go"))
+	if got["GOPROXY"] != "https://proxy.golang.org,direct" || got["GOTOOLCHAIN"] != "auto" {
+		t.Fatalf("fallback defaults = %v", got)
+	}
+	if got["GOPATH"] == "" || got["GOCACHE"] == "" {
+		t.Fatalf("fallback GOPATH/GOCACHE missing: %v", got)
+	}
+}
@@ -0,0 +1,49 @@
+// Package output serializes command results for scripts: JSON or YAML.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// SchemaVersion is reported in every document and bumped on incompatible
+// changes of the document layout.
+const SchemaVersion = 1
+
+// Validate checks a --format value.
+func Validate(format string) error {
+	switch format {
+	case FormatText, FormatJSON, FormatYAML:
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q (expected text, json or yaml)", format)
+	}
+}
+
+// Encode writes v as JSON or YAML. Struct fields are named and ordered
+// by their json tags in both formats.
+func Encode(w io.Writer, format string, v any) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case FormatYAML:
+		b, err := MarshalYAML(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	default:
+		return fmt.Errorf("format %q cannot be encoded", format)
+	}
+}
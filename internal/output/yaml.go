@@ -0,0 +1,190 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MarshalYAML renders v as block-style YAML. It supports what the output
+// documents are made of: structs (json tags, omitempty), maps with string
+// keys (sorted), slices, strings, bools, numbers and nil pointers.
+// Strings are always double-quoted, so values like "off" or "" stay strings.
+func MarshalYAML(v any) ([]byte, error) {
+	var b bytes.Buffer
+	if err := writeYAML(&b, reflect.ValueOf(v), 0, false); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeYAML(b *bytes.Buffer, v reflect.Value, indent int, inline bool) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			b.WriteString("null\n")
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	pad := strings.Repeat("  ", indent)
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := structFields(v)
+		if len(fields) == 0 {
+			b.WriteString("{}\n")
+			return nil
+		}
+		if inline {
+			b.WriteString("\n")
+		}
+		for _, f := range fields {
+			b.WriteString(pad + f.name + ":")
+			if err := writeValue(b, f.value, indent+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("yaml: unsupported map key type %s", v.Type().Key())
+		}
+		if v.Len() == 0 {
+			b.WriteString("{}\n")
+			return nil
+		}
+		if inline {
+			b.WriteString("\n")
+		}
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteString(pad + quoteYAML(k) + ":")
+			if err := writeValue(b, v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key())), indent+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			b.WriteString("[]\n")
+			return nil
+		}
+		if inline {
+			b.WriteString("\n")
+		}
+		for i := 0; i < v.Len(); i++ {
+			elem := v.Index(i)
+			if !isCollection(elem) {
+				b.WriteString(pad + "-")
+				if err := writeValue(b, elem, indent+1); err != nil {
+					return err
+				}
+				continue
+			}
+			// compact form: the first line of the element goes right after "- "
+			var tmp bytes.Buffer
+			if err := writeYAML(&tmp, elem, indent+1, false); err != nil {
+				return err
+			}
+			b.WriteString(pad + "- ")
+			b.WriteString(strings.TrimPrefix(tmp.String(), pad+"  "))
+		}
+	default:
+		s, err := scalarYAML(v)
+		if err != nil {
+			return err
+		}
+		b.WriteString(s + "\n")
+	}
+	return nil
+}
+
+// writeValue writes the value that follows "key:" or "-".
+func writeValue(b *bytes.Buffer, v reflect.Value, indent int) error {
+	b.WriteString(" ")
+	if isCollection(v) {
+		// drop the space, collections start on the next line
+		b.Truncate(b.Len() - 1)
+		return writeYAML(b, v, indent, true)
+	}
+	return writeYAML(b, v, indent, false)
+}
+
+func isCollection(v reflect.Value) bool {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return len(structFields(v)) > 0
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return v.Len() > 0
+	}
+	return false
+}
+
+func scalarYAML(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return quoteYAML(v.String()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	default:
+		return "", fmt.Errorf("yaml: unsupported type %s", v.Type())
+	}
+}
+
+// quoteYAML uses JSON string syntax, which is a valid YAML double-quoted scalar.
+func quoteYAML(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+type field struct {
+	name  string
+	value reflect.Value
+}
+
+// structFields lists exported fields named by their json tags,
+// skipping "-" and empty omitempty fields like encoding/json does.
+func structFields(v reflect.Value) []field {
+	var out []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fv := v.Field(i)
+		if strings.Contains(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		out = append(out, field{name: name, value: fv})
+	}
+	return out
+}
@@ -0,0 +1,47 @@
+package output
+
+import "testing"
+
+func TestMarshalYAML(t *testing.T) {
+	type item struct {
+		Name   string  `json:"name"`
+		Active bool    `json:"active"`
+		Env    *string `json:"env"`
+		Origin string  `json:"origin,omitempty"`
+	}
+	type doc struct {
+		Version int               `json:"version"`
+		Items   []item            `json:"items"`
+		Vars    map[string]string `json:"vars"`
+		Empty   []item            `json:"empty"`
+	}
+	v := "off"
+	got, err := MarshalYAML(doc{
+		Version: 1,
+		Items: []item{
+			{Name: "corp", Active: true, Env: &v},
+			{Name: "public", Origin: "base"},
+		},
+		Vars: map[string]string{"GOPROXY": "off", "GOFLAGS": `-tags="a b"`},
+	})
+	if err != nil {
+		t.Fatalf("MarshalYAML error: %v", err)
+	}
+	want := `version: 1
+items:
+  - name: "corp"
+    active: true
+    env: "off"
+  - name: "public"
+    active: false
+    env: null
+    origin: "base"
+vars:
+  "GOFLAGS": "-tags=\"a b\""
+  "GOPROXY": "off"
+empty: []
+`
+	if string(got) != want {
+		t.Fatalf("MarshalYAML got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -4,7 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"time"
+
+	"github.com/ZeraiGR/gpx/internal/fsx"
 )
 
 const (
@@ -15,17 +16,74 @@ const (
 type ApplyOptions struct {
 	DryRun bool
 	Backup bool
+	// Markers of the block to manage; zero value means ProfileMarkers.
+	// ProfileMarkers.Named gives those of a named block.
+	Markers Markers
+	// Repair collapses malformed or duplicate blocks into one instead of
+	// failing with a *BlockError.
+	Repair bool
+	// ReplaceSymlink lets a write replace an rc symlink with a regular file
+	// when its target is read-only, instead of failing.
+	ReplaceSymlink bool
+	// Retention prunes old backups after Backup made a new one.
+	Retention Retention
 }
 
 type ApplyResult struct {
-	RCPath      string
-	BackupPath  string
+	RCPath string
+	// Target is the file actually written: RCPath with symlinks resolved.
+	Target     string
+	BackupPath string
+	// Pruned lists the old backups removed by opts.Retention.
+	Pruned      []string
 	WouldChange bool
 	NewContent  string // filled for DryRun (and can be useful for debugging)
 }
 
+// BlockMarkers returns o.Markers, or ProfileMarkers if unset.
+func (o ApplyOptions) BlockMarkers() Markers {
+	if o.Markers == (Markers{}) {
+		return ProfileMarkers
+	}
+	return o.Markers
+}
+
 func ApplyToRC(rcPath string, lines []string, opts ApplyOptions) (*ApplyResult, error) {
-	block := RenderBlock(lines)
+	m := opts.BlockMarkers()
+	block := m.Render(lines)
+
+	return UpdateFile(rcPath, func(old string) (string, error) {
+		if err := m.Check(old); err != nil {
+			if !opts.Repair {
+				return "", fmt.Errorf("rc %s: %w", rcPath, err)
+			}
+			return m.Repair(old, block), nil
+		}
+		return m.Upsert(old, block), nil
+	}, opts)
+}
+
+// UpdateFile rewrites a file through update with the same semantics as ApplyToRC:
+// a missing file reads as empty, DryRun does not touch the filesystem,
+// Backup copies the old file first, and the new content replaces it atomically.
+//
+// A symlink at rcPath is written through: the file it points to is replaced
+// and keeps its mode and owner (see fsx.ReplaceFile). If that file or its
+// directory is read-only, UpdateFile fails unless opts.ReplaceSymlink is set,
+// in which case the link itself is replaced by a regular file.
+func UpdateFile(rcPath string, update func(old string) (string, error), opts ApplyOptions) (*ApplyResult, error) {
+	target, err := fsx.Resolve(rcPath)
+	if err != nil {
+		return nil, fmt.Errorf("rc %s: %w", rcPath, err)
+	}
+	replaceLink := false
+	if target != rcPath && readOnly(target) {
+		if !opts.ReplaceSymlink {
+			return nil, fmt.Errorf("rc %s is a symlink to %s in a read-only location; "+
+				"use --replace-symlink to write a regular file in place of the link", rcPath, target)
+		}
+		replaceLink = true
+	}
 
 	old := ""
 	if b, err := os.ReadFile(rcPath); err == nil {
@@ -34,11 +92,15 @@ func ApplyToRC(rcPath string, lines []string, opts ApplyOptions) (*ApplyResult,
 		return nil, fmt.Errorf("read rc %s: %w", rcPath, err)
 	}
 
-	newContent := UpsertBlock(old, block)
+	newContent, err := update(old)
+	if err != nil {
+		return nil, err
+	}
 	wouldChange := newContent != old
 
 	res := &ApplyResult{
 		RCPath:      rcPath,
+		Target:      target,
 		WouldChange: wouldChange,
 		NewContent:  newContent,
 	}
@@ -48,48 +110,56 @@ func ApplyToRC(rcPath string, lines []string, opts ApplyOptions) (*ApplyResult,
 		return res, nil
 	}
 
+	if replaceLink {
+		res.Target = rcPath
+	}
+
 	// Ensure dir exists
-	dir := filepath.Dir(rcPath)
+	dir := filepath.Dir(res.Target)
 	if err := os.MkdirAll(dir, RcDirPerm); err != nil {
 		return nil, fmt.Errorf("mkdir %s: %w", dir, err)
 	}
 
 	// Backup existing file (optional)
 	if opts.Backup {
-		if _, err := os.Stat(rcPath); err == nil {
-			bak := backupPath(rcPath)
-			if err := copyFile(rcPath, bak, RcFilePerm); err != nil {
-				return nil, fmt.Errorf("backup rc to %s: %w", bak, err)
+		if fi, err := os.Stat(rcPath); err == nil {
+			bak, err := createBackup(rcPath, fi.Mode().Perm())
+			if err != nil {
+				return nil, fmt.Errorf("backup rc %s: %w", rcPath, err)
 			}
 			res.BackupPath = bak
+			// best-effort: a failed cleanup must not block the write
+			res.Pruned, _ = PruneBackups(rcPath, opts.Retention)
 		} else if err != nil && !os.IsNotExist(err) {
 			return nil, fmt.Errorf("stat rc %s: %w", rcPath, err)
 		}
 	}
 
 	// Atomic replace
-	tmp := rcPath + ".tmp"
-	if err := os.WriteFile(tmp, []byte(newContent), RcFilePerm); err != nil {
-		return nil, fmt.Errorf("write temp rc %s: %w", tmp, err)
+	if replaceLink {
+		perm := RcFilePerm
+		if fi, err := os.Stat(target); err == nil {
+			perm = fi.Mode().Perm() | 0o200
+		}
+		err = fsx.WriteFile(rcPath, []byte(newContent), perm)
+	} else {
+		_, err = fsx.ReplaceFile(rcPath, []byte(newContent), RcFilePerm)
 	}
-	if err := os.Rename(tmp, rcPath); err != nil {
+	if err != nil {
 		return nil, fmt.Errorf("replace rc %s: %w", rcPath, err)
 	}
 
 	return res, nil
 }
 
-func backupPath(rcPath string) string {
-	// Keep it simple and safe:
-	// ~/.zshrc -> ~/.zshrc.gpx.bak (with timestamp to avoid overwriting)
-	ts := time.Now().Format("20060102-150405")
-	return fmt.Sprintf("%s.gpx.%s.bak", rcPath, ts)
-}
-
-func copyFile(src, dst string, perm os.FileMode) error {
-	b, err := os.ReadFile(src)
-	if err != nil {
-		return err
+// readOnly reports whether target, or the directory holding it, exists but
+// cannot be written. It is a variable so tests can make a location read-only
+// whatever uid they run as.
+var readOnly = func(target string) bool {
+	for _, p := range []string{filepath.Dir(target), target} {
+		if _, err := os.Stat(p); err == nil && !fsx.Writable(p) {
+			return true
+		}
 	}
-	return os.WriteFile(dst, b, perm)
+	return false
 }
@@ -0,0 +1,181 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backupTimeLayout is the timestamp in backup names: ~/.zshrc.gpx.<ts>.bak,
+// or ~/.zshrc.gpx.<ts>-<n>.bak for the n-th further backup in one second.
+const backupTimeLayout = "20060102-150405"
+
+// Backup is a copy of an rc file made by UpdateFile with ApplyOptions.Backup.
+type Backup struct {
+	Path string
+	// ID is the timestamp part of the name with its counter, if any;
+	// FindBackup accepts it.
+	ID   string
+	Time time.Time
+	Size int64
+
+	seq int // counter of backups taken in the same second
+}
+
+// Retention bounds the backups kept per rc file; zero values mean no limit.
+type Retention struct {
+	Keep   int
+	MaxAge time.Duration
+}
+
+// createBackup copies rcPath to a new backup file and returns its path.
+// Names are taken with O_EXCL, so two backups in the same second (or two gpx
+// processes) never overwrite each other: the later one gets a counter.
+func createBackup(rcPath string, perm os.FileMode) (string, error) {
+	b, err := os.ReadFile(rcPath)
+	if err != nil {
+		return "", err
+	}
+	ts := time.Now().Format(backupTimeLayout)
+	for n := 0; ; n++ {
+		id := ts
+		if n > 0 {
+			id = fmt.Sprintf("%s-%d", ts, n)
+		}
+		path := fmt.Sprintf("%s.gpx.%s.bak", rcPath, id)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(b); err != nil {
+			f.Close()
+			os.Remove(path)
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		return path, nil
+	}
+}
+
+// parseBackupID splits a backup ID into its time and counter.
+func parseBackupID(id string) (time.Time, int, bool) {
+	ts, seq := id, 0
+	if len(id) > len(backupTimeLayout) {
+		n, err := strconv.Atoi(strings.TrimPrefix(id[len(backupTimeLayout):], "-"))
+		if id[len(backupTimeLayout)] != '-' || err != nil || n < 1 {
+			return time.Time{}, 0, false
+		}
+		ts, seq = id[:len(backupTimeLayout)], n
+	}
+	t, err := time.ParseInLocation(backupTimeLayout, ts, time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return t, seq, true
+}
+
+// Backups lists the backups of rcPath, newest first.
+func Backups(rcPath string) ([]Backup, error) {
+	dir, base := filepath.Split(rcPath)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list backups of %s: %w", rcPath, err)
+	}
+	var out []Backup
+	for _, e := range entries {
+		id, ok := strings.CutPrefix(e.Name(), base+".gpx.")
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		if id, ok = strings.CutSuffix(id, ".bak"); !ok {
+			continue
+		}
+		ts, seq, ok := parseBackupID(id)
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, Backup{Path: filepath.Join(dir, e.Name()), ID: id, Time: ts, Size: info.Size(), seq: seq})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Time.Equal(out[j].Time) {
+			return out[i].Time.After(out[j].Time)
+		}
+		return out[i].seq > out[j].seq
+	})
+	return out, nil
+}
+
+// FindBackup returns the backup of rcPath named by ref: its ID, its path,
+// or the newest one when ref is "".
+func FindBackup(rcPath, ref string) (Backup, error) {
+	backups, err := Backups(rcPath)
+	if err != nil {
+		return Backup{}, err
+	}
+	if len(backups) == 0 {
+		return Backup{}, fmt.Errorf("no backups of %s", rcPath)
+	}
+	if ref == "" {
+		return backups[0], nil
+	}
+	for _, b := range backups {
+		if b.ID == ref || b.Path == ref || filepath.Base(b.Path) == ref {
+			return b, nil
+		}
+	}
+	if abs, err := filepath.Abs(ref); err == nil {
+		for _, b := range backups {
+			if babs, err := filepath.Abs(b.Path); err == nil && babs == abs {
+				return b, nil
+			}
+		}
+	}
+	return Backup{}, fmt.Errorf("backup %q of %s not found (see gpx backups)", ref, rcPath)
+}
+
+// PruneBackups removes the backups of rcPath that r does not keep: all but
+// the r.Keep newest, and those older than r.MaxAge. The newest backup is
+// never removed. It returns the removed paths.
+func PruneBackups(rcPath string, r Retention) ([]string, error) {
+	if r == (Retention{}) {
+		return nil, nil
+	}
+	backups, err := Backups(rcPath)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for i, b := range backups {
+		if i == 0 {
+			continue
+		}
+		expired := r.MaxAge > 0 && time.Since(b.Time) > r.MaxAge
+		if (r.Keep > 0 && i >= r.Keep) || expired {
+			if err := os.Remove(b.Path); err != nil {
+				return removed, fmt.Errorf("remove backup %s: %w", b.Path, err)
+			}
+			removed = append(removed, b.Path)
+		}
+	}
+	return removed, nil
+}
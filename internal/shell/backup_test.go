@@ -0,0 +1,110 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeBackups(t *testing.T, rc string, ages ...time.Duration) {
+	t.Helper()
+	now := time.Now()
+	for _, age := range ages {
+		p := rc + ".gpx." + now.Add(-age).Format(backupTimeLayout) + ".bak"
+		if err := os.WriteFile(p, []byte(age.String()), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBackups_NewestFirst(t *testing.T) {
+	dir := t.TempDir()
+	rc := filepath.Join(dir, ".zshrc")
+	writeBackups(t, rc, 2*time.Hour, time.Hour, 3*time.Hour)
+	// not backups of rc
+	for _, name := range []string{".zshrc.gpx.junk.bak", ".zshrc.lock", ".zshrc-other.gpx.20260101-000000.bak"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Backups(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d backups, want 3: %+v", len(got), got)
+	}
+	for i := 1; i < len(got); i++ {
+		if !got[i-1].Time.After(got[i].Time) {
+			t.Fatalf("backups not newest first: %+v", got)
+		}
+	}
+
+	if b, err := FindBackup(rc, ""); err != nil || b.Path != got[0].Path {
+		t.Fatalf("FindBackup(\"\") = %+v, %v; want the newest", b, err)
+	}
+	if b, err := FindBackup(rc, got[2].ID); err != nil || b.Path != got[2].Path {
+		t.Fatalf("FindBackup(id) = %+v, %v", b, err)
+	}
+	if _, err := FindBackup(rc, "20000101-000000"); err == nil {
+		t.Fatal("FindBackup found a missing backup")
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	rc := filepath.Join(t.TempDir(), ".bashrc")
+	writeBackups(t, rc, time.Minute, time.Hour, 48*time.Hour, 72*time.Hour)
+
+	removed, err := PruneBackups(rc, Retention{MaxAge: 24 * time.Hour})
+	if err != nil || len(removed) != 2 {
+		t.Fatalf("PruneBackups(max age) removed %v, %v; want the 2 old ones", removed, err)
+	}
+	removed, err = PruneBackups(rc, Retention{Keep: 1})
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("PruneBackups(keep 1) removed %v, %v", removed, err)
+	}
+	removed, err = PruneBackups(rc, Retention{MaxAge: time.Nanosecond})
+	if err != nil || len(removed) != 0 {
+		t.Fatalf("PruneBackups removed the newest backup: %v, %v", removed, err)
+	}
+	if got, _ := Backups(rc); len(got) != 1 {
+		t.Fatalf("%d backups left, want 1", len(got))
+	}
+}
+
+func TestCreateBackup_SameSecond(t *testing.T) {
+	rc := filepath.Join(t.TempDir(), ".zshrc")
+	var paths []string
+	for _, content := range []string{"one\n", "two\n", "three\n"} {
+		if err := os.WriteFile(rc, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		p, err := createBackup(rc, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	if paths[0] == paths[1] || paths[1] == paths[2] {
+		t.Fatalf("backups overwrote each other: %v", paths)
+	}
+
+	got, err := Backups(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d backups, want 3: %+v", len(got), got)
+	}
+	// newest first, also within one second
+	for i, want := range []string{"three\n", "two\n", "one\n"} {
+		if b, _ := os.ReadFile(got[i].Path); string(b) != want {
+			t.Fatalf("backup %d (%s) = %q, want %q", i, got[i].ID, b, want)
+		}
+	}
+	if b, err := FindBackup(rc, got[0].ID); err != nil || b.Path != got[0].Path {
+		t.Fatalf("FindBackup(%s) = %+v, %v", got[0].ID, b, err)
+	}
+}
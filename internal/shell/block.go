@@ -1,6 +1,8 @@
 package shell
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -9,39 +11,206 @@ const (
 	EndMarker   = "# GPX_END"
 )
 
-// RenderBlock builds the managed block content.
-// It ALWAYS ends with a trailing newline.
-func RenderBlock(lines []string) string {
+// Markers delimit one managed block in an rc file.
+type Markers struct {
+	Begin string
+	End   string
+}
+
+var (
+	// ProfileMarkers wrap the block written by `gpx apply`.
+	ProfileMarkers = Markers{Begin: BeginMarker, End: EndMarker}
+	// InitMarkers wrap the block written by `gpx shell-init --install`.
+	InitMarkers = Markers{Begin: "# GPX_INIT_BEGIN", End: "# GPX_INIT_END"}
+)
+
+// Named returns the markers of the block called name, e.g.
+// "# GPX_BEGIN corp" … "# GPX_END corp". The unnamed block is m itself.
+func (m Markers) Named(name string) Markers {
+	if name == "" {
+		return m
+	}
+	return Markers{Begin: m.Begin + " " + name, End: m.End + " " + name}
+}
+
+// ValidateBlockName checks that a block name fits on the marker line:
+// letters, digits, '.', '_' and '-'.
+func ValidateBlockName(name string) error {
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._-", r)) {
+			return fmt.Errorf("invalid block name %q: use letters, digits, '.', '_' and '-'", name)
+		}
+	}
+	return nil
+}
+
+// RenderBlock builds the content of the GPX block called name ("" for the
+// unnamed one). It ALWAYS ends with a trailing newline.
+func RenderBlock(name string, lines []string) string {
+	return ProfileMarkers.Named(name).Render(lines)
+}
+
+// UpsertBlock inserts or replaces the GPX block called name inside rc file content.
+// - If both markers exist: replace everything between them (inclusive).
+// - If no markers: append block at the end, separated by a newline if needed.
+// Blocks with other names are left alone.
+func UpsertBlock(name, rcContent, block string) string {
+	return ProfileMarkers.Named(name).Upsert(rcContent, block)
+}
+
+// RemoveBlock takes the GPX block called name out of rc file content.
+// It reports false (and returns rcContent as is) if there is no block.
+func RemoveBlock(name, rcContent string) (string, bool) {
+	return ProfileMarkers.Named(name).Remove(rcContent)
+}
+
+// Blocks returns the complete blocks of m and of every name (see Named) in
+// rc content, in file order, with Block.Name set.
+func (m Markers) Blocks(rcContent string) []Block {
+	names := map[string]bool{"": true}
+	for _, ln := range splitLines(rcContent) {
+		t := strings.TrimSpace(ln.text)
+		if name, ok := strings.CutPrefix(t, m.Begin+" "); ok && ValidateBlockName(name) == nil && name != "" {
+			names[name] = true
+		}
+	}
+	var out []Block
+	for name := range names {
+		blocks, _ := m.Named(name).Parse(rcContent)
+		for _, b := range blocks {
+			b.Name = name
+			out = append(out, b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].start < out[j].start })
+	return out
+}
+
+// Render is RenderBlock for an arbitrary pair of markers.
+func (m Markers) Render(lines []string) string {
 	var b strings.Builder
-	b.WriteString(BeginMarker)
+	b.WriteString(m.Begin)
 	b.WriteString("\n")
 	for _, ln := range lines {
 		b.WriteString(ln)
 		b.WriteString("\n")
 	}
-	b.WriteString(EndMarker)
+	b.WriteString(m.End)
 	b.WriteString("\n")
 	return b.String()
 }
 
-// UpsertBlock inserts or replaces the GPX block inside rc file content.
-// - If both markers exist: replace everything between them (inclusive).
-// - If no markers: append block at the end, separated by a newline if needed.
-func UpsertBlock(rcContent string, block string) string {
-	begin := strings.Index(rcContent, BeginMarker)
-	end := strings.Index(rcContent, EndMarker)
-
-	if begin != -1 && end != -1 && end >= begin {
-		// include EndMarker line
-		endLine := end + len(EndMarker)
-		// extend to end-of-line if present
-		if endLine < len(rcContent) && rcContent[endLine] == '\r' {
-			endLine++
+// Block is a managed block found in rc content.
+type Block struct {
+	Name               string   // set by Blocks; "" for the unnamed block
+	BeginLine, EndLine int      // 1-based line numbers of the markers
+	Lines              []string // between the markers, without line endings
+
+	start, end int // byte span, from the begin marker to after the end line
+}
+
+// Anomaly is a problem with the markers of an rc file.
+type Anomaly struct {
+	Line int // 1-based
+	Msg  string
+}
+
+func (a Anomaly) String() string { return fmt.Sprintf("line %d: %s", a.Line, a.Msg) }
+
+// BlockError reports an rc file whose markers do not form at most one
+// well-formed block. Changing such a file could mangle it, so the block
+// has to be repaired (see Markers.Repair) or fixed by hand first.
+type BlockError struct {
+	Markers   Markers
+	Anomalies []Anomaly
+}
+
+func (e *BlockError) Error() string {
+	parts := make([]string, len(e.Anomalies))
+	for i, a := range e.Anomalies {
+		parts[i] = a.String()
+	}
+	return fmt.Sprintf("malformed %s block: %s", e.Markers.Begin, strings.Join(parts, "; "))
+}
+
+// rcLine is a line of rc content with its byte span, line ending included.
+type rcLine struct {
+	text       string // without the line ending
+	start, end int
+}
+
+func splitLines(content string) []rcLine {
+	var out []rcLine
+	for start := 0; start < len(content); {
+		end := strings.IndexByte(content[start:], '\n')
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += start + 1
 		}
-		if endLine < len(rcContent) && rcContent[endLine] == '\n' {
-			endLine++
+		text := strings.TrimSuffix(strings.TrimSuffix(content[start:end], "\n"), "\r")
+		out = append(out, rcLine{text: text, start: start, end: end})
+		start = end
+	}
+	return out
+}
+
+// isMarker reports whether a line is the marker itself. Marker text inside
+// another line, e.g. a comment mentioning it, is not a marker.
+func isMarker(line, marker string) bool {
+	return strings.TrimSpace(line) == marker
+}
+
+// Parse finds the blocks of rc content line by line. Besides the complete
+// blocks it returns the anomalies: more than one block, an end marker
+// without a begin marker, a begin marker inside a block or without an end.
+func (m Markers) Parse(rcContent string) ([]Block, []Anomaly) {
+	var (
+		blocks    []Block
+		anomalies []Anomaly
+		open      = -1 // index in lines of the open begin marker
+	)
+	lines := splitLines(rcContent)
+	for i, ln := range lines {
+		switch {
+		case isMarker(ln.text, m.Begin) && open >= 0:
+			anomalies = append(anomalies, Anomaly{i + 1, fmt.Sprintf("%s inside the block started at line %d", m.Begin, open+1)})
+		case isMarker(ln.text, m.Begin):
+			open = i
+		case isMarker(ln.text, m.End) && open < 0:
+			anomalies = append(anomalies, Anomaly{i + 1, fmt.Sprintf("%s without %s", m.End, m.Begin)})
+		case isMarker(ln.text, m.End):
+			b := Block{BeginLine: open + 1, EndLine: i + 1, start: lines[open].start, end: ln.end}
+			for _, inner := range lines[open+1 : i] {
+				b.Lines = append(b.Lines, inner.text)
+			}
+			if len(blocks) > 0 {
+				anomalies = append(anomalies, Anomaly{open + 1, fmt.Sprintf("another block (the first one is at line %d)", blocks[0].BeginLine)})
+			}
+			blocks = append(blocks, b)
+			open = -1
 		}
-		return rcContent[:begin] + block + rcContent[endLine:]
+	}
+	if open >= 0 {
+		anomalies = append(anomalies, Anomaly{open + 1, fmt.Sprintf("%s without %s", m.Begin, m.End)})
+	}
+	return blocks, anomalies
+}
+
+// Check returns a *BlockError if rc content has anomalies (see Parse).
+func (m Markers) Check(rcContent string) error {
+	if _, anomalies := m.Parse(rcContent); len(anomalies) > 0 {
+		return &BlockError{Markers: m, Anomalies: anomalies}
+	}
+	return nil
+}
+
+// Upsert is UpsertBlock for an arbitrary pair of markers. It replaces the
+// first complete block; callers should Check the content first.
+func (m Markers) Upsert(rcContent string, block string) string {
+	if blocks, _ := m.Parse(rcContent); len(blocks) > 0 {
+		b := blocks[0]
+		return rcContent[:b.start] + block + rcContent[b.end:]
 	}
 
 	trimmed := strings.TrimRight(rcContent, "\r\n")
@@ -50,3 +219,73 @@ func UpsertBlock(rcContent string, block string) string {
 	}
 	return trimmed + "\n\n" + block
 }
+
+// Repair collapses all blocks into one: every complete block and stray
+// marker line is removed, and block is put where the first complete block
+// was, or appended. Lines after a begin marker without an end marker are
+// kept, as it is not known where that block was meant to end; the appended
+// block comes after them, so its values win.
+func (m Markers) Repair(rcContent string, block string) string {
+	var out strings.Builder
+	placed := false
+	blocks, _ := m.Parse(rcContent)
+	for _, ln := range splitLines(rcContent) {
+		switch {
+		case len(blocks) > 0 && ln.start >= blocks[0].start && ln.end <= blocks[0].end:
+			if !placed {
+				out.WriteString(block)
+				placed = true
+			}
+			if ln.end == blocks[0].end {
+				blocks = blocks[1:]
+			}
+		case isMarker(ln.text, m.Begin) || isMarker(ln.text, m.End):
+			// stray marker
+		default:
+			out.WriteString(rcContent[ln.start:ln.end])
+		}
+	}
+	if !placed {
+		return m.Upsert(out.String(), block)
+	}
+	return out.String()
+}
+
+// Remove is RemoveBlock for an arbitrary pair of markers. Along with the
+// first complete block it drops the blank line Upsert put in front of it,
+// so removing an appended block restores the file as it was.
+func (m Markers) Remove(rcContent string) (string, bool) {
+	blocks, _ := m.Parse(rcContent)
+	if len(blocks) == 0 {
+		return rcContent, false
+	}
+	before, after := rcContent[:blocks[0].start], rcContent[blocks[0].end:]
+	switch {
+	case strings.HasSuffix(before, "\r\n\r\n"):
+		before = strings.TrimSuffix(before, "\r\n")
+	case strings.HasSuffix(before, "\n\n"):
+		before = strings.TrimSuffix(before, "\n")
+	}
+	return before + after, true
+}
+
+// ReadBlock returns the lines between the markers of the first complete
+// block, without the markers. It reports false if there is none.
+func (m Markers) ReadBlock(rcContent string) ([]string, bool) {
+	blocks, _ := m.Parse(rcContent)
+	if len(blocks) == 0 {
+		return nil, false
+	}
+	// blank lines around the content are not part of it
+	lines := blocks[0].Lines
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil, true
+	}
+	return lines, true
+}
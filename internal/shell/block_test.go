@@ -1,22 +1,26 @@
 package shell
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
 
 func TestUpsertBlock_InsertIntoEmpty(t *testing.T) {
-	block := RenderBlock([]string{"export GOPROXY='x'"})
-	got := UpsertBlock("", block)
+	block := RenderBlock("", []string{"export GOPROXY='x'"})
+	got := UpsertBlock("", "", block)
 	if got != block {
 		t.Fatalf("got:\n%q\nwant:\n%q", got, block)
 	}
 }
 
 func TestUpsertBlock_AppendWhenNoMarkers(t *testing.T) {
-	block := RenderBlock([]string{"export GOPROXY='x'"})
+	block := RenderBlock("", []string{"export GOPROXY='x'"})
 	rc := "export PATH=$PATH\n"
-	got := UpsertBlock(rc, block)
+	got := UpsertBlock("", rc, block)
 	if got == rc {
 		t.Fatalf("expected updated content")
 	}
@@ -33,8 +37,8 @@ func TestUpsertBlock_ReplaceExisting(t *testing.T) {
 		EndMarker + "\n" +
 		"alias ll='ls -la'\n"
 
-	block := RenderBlock([]string{"export GOPROXY='new'"})
-	got := UpsertBlock(old, block)
+	block := RenderBlock("", []string{"export GOPROXY='new'"})
+	got := UpsertBlock("", old, block)
 
 	if !containsAll(got, "export GOPROXY='new'") {
 		t.Fatalf("expected new value, got:\n%s", got)
@@ -44,6 +48,30 @@ func TestUpsertBlock_ReplaceExisting(t *testing.T) {
 	}
 }
 
+func TestUpsertBlock_InitBlockIsIndependent(t *testing.T) {
+	rc := InitMarkers.Render([]string{`eval "$(command gpx shell-init zsh)"`})
+	rc = UpsertBlock("", rc, RenderBlock("", []string{"export GOPROXY='old'"}))
+	rc = UpsertBlock("", rc, RenderBlock("", []string{"export GOPROXY='new'"}))
+
+	if !containsAll(rc, InitMarkers.Begin, "shell-init zsh", "export GOPROXY='new'") {
+		t.Fatalf("expected both blocks, got:\n%s", rc)
+	}
+	if strings.Count(rc, BeginMarker) != 1 {
+		t.Fatalf("expected a single profile block, got:\n%s", rc)
+	}
+}
+
+func TestReadBlock(t *testing.T) {
+	rc := "export PATH=$PATH\n\n" + RenderBlock("", []string{"export GOPROXY='x'", "export GOFLAGS=''"})
+	got, ok := ProfileMarkers.ReadBlock(rc)
+	if !ok || len(got) != 2 || got[0] != "export GOPROXY='x'" {
+		t.Fatalf("ReadBlock got %#v, %v", got, ok)
+	}
+	if _, ok := ProfileMarkers.ReadBlock("export PATH=$PATH\n"); ok {
+		t.Fatalf("expected no block")
+	}
+}
+
 func containsAll(s string, subs ...string) bool {
 	for _, sub := range subs {
 		if !strings.Contains(s, sub) {
@@ -52,3 +80,229 @@ func containsAll(s string, subs ...string) bool {
 	}
 	return true
 }
+
+func TestRemoveBlock(t *testing.T) {
+	block := RenderBlock("", []string{"export GOPROXY='x'"})
+	tests := []struct {
+		name, rc, want string
+	}{
+		{"only block", block, ""},
+		{"appended", UpsertBlock("", "export PATH=$PATH\n", block), "export PATH=$PATH\n"},
+		{"in the middle", "a\n\n" + block + "\nb\n", "a\n\nb\n"},
+		{"no blank before", "a\n" + block + "b\n", "a\nb\n"},
+		{"crlf", "a\r\n\r\n" + strings.ReplaceAll(block, "\n", "\r\n"), "a\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RemoveBlock("", tt.rc)
+			if !ok || got != tt.want {
+				t.Fatalf("RemoveBlock = %q, %v; want %q", got, ok, tt.want)
+			}
+		})
+	}
+
+	rc := InitMarkers.Render([]string{"eval x"})
+	if got, ok := RemoveBlock("", rc); ok || got != rc {
+		t.Fatalf("RemoveBlock without a profile block = %q, %v", got, ok)
+	}
+}
+
+func TestParse_Anomalies(t *testing.T) {
+	const (
+		b = BeginMarker
+		e = EndMarker
+	)
+	tests := []struct {
+		name       string
+		rc         string
+		wantBlocks int
+		wantLines  []int // lines of the anomalies
+	}{
+		{"none", "export PATH=$PATH\n", 0, nil},
+		{"one block", "x\n" + b + "\nexport A=1\n" + e + "\n", 1, nil},
+		{"two blocks", b + "\n" + e + "\nx\n" + b + "\n" + e + "\n", 2, []int{4}},
+		{"end before begin", e + "\nx\n" + b + "\n" + e + "\n", 1, []int{1}},
+		{"begin without end", "x\n" + b + "\nexport A=1\n", 0, []int{2}},
+		{"nested begin", b + "\n" + b + "\n" + e + "\n", 1, []int{2}},
+		{"marker text in a comment", "# remove " + b + " to reset\n" + b + "\n" + e + "\n", 1, nil},
+		{"indented markers", "  " + b + "\n  " + e + "\n", 1, nil},
+		{"crlf", b + "\r\nexport A=1\r\n" + e + "\r\n", 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks, anomalies := ProfileMarkers.Parse(tt.rc)
+			if len(blocks) != tt.wantBlocks {
+				t.Errorf("blocks = %d, want %d", len(blocks), tt.wantBlocks)
+			}
+			var lines []int
+			for _, a := range anomalies {
+				lines = append(lines, a.Line)
+			}
+			if !slices.Equal(lines, tt.wantLines) {
+				t.Errorf("anomalies at lines %v, want %v (%v)", lines, tt.wantLines, anomalies)
+			}
+			if err := ProfileMarkers.Check(tt.rc); (err != nil) != (len(tt.wantLines) > 0) {
+				t.Errorf("Check = %v", err)
+			}
+		})
+	}
+}
+
+func TestRepair(t *testing.T) {
+	block := RenderBlock("", []string{"export GOPROXY='new'"})
+	old := func(v string) string { return RenderBlock("", []string{"export GOPROXY='" + v + "'"}) }
+	tests := []struct {
+		name, rc, want string
+	}{
+		{"duplicates", "a\n" + old("1") + "b\n" + old("2") + "c\n", "a\n" + block + "b\nc\n"},
+		{"stray end", EndMarker + "\na\n" + old("1"), "a\n" + block},
+		{
+			"begin without end",
+			"a\n" + BeginMarker + "\nexport GOPROXY='1'\n",
+			"a\nexport GOPROXY='1'\n\n" + block,
+		},
+		{"well-formed", "a\n" + old("1") + "b\n", "a\n" + block + "b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ProfileMarkers.Repair(tt.rc, block)
+			if got != tt.want {
+				t.Fatalf("Repair =\n%q\nwant\n%q", got, tt.want)
+			}
+			if err := ProfileMarkers.Check(got); err != nil {
+				t.Fatalf("repaired content: %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyToRC_RefusesMalformedBlock(t *testing.T) {
+	rc := filepath.Join(t.TempDir(), ".zshrc")
+	content := "a\n" + RenderBlock("", nil) + RenderBlock("", nil)
+	if err := os.WriteFile(rc, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ApplyToRC(rc, []string{"export A=1"}, ApplyOptions{})
+	var berr *BlockError
+	if !errors.As(err, &berr) {
+		t.Fatalf("ApplyToRC error = %v, want BlockError", err)
+	}
+	if b, _ := os.ReadFile(rc); string(b) != content {
+		t.Fatalf("rc file changed:\n%s", b)
+	}
+
+	if _, err := ApplyToRC(rc, []string{"export A=1"}, ApplyOptions{Repair: true}); err != nil {
+		t.Fatalf("ApplyToRC with Repair: %v", err)
+	}
+	b, _ := os.ReadFile(rc)
+	if strings.Count(string(b), BeginMarker) != 1 || !strings.Contains(string(b), "export A=1") {
+		t.Fatalf("repaired rc:\n%s", b)
+	}
+}
+
+func TestNamedBlocks_AreIndependent(t *testing.T) {
+	rc := UpsertBlock("", "export PATH=$PATH\n", RenderBlock("", []string{"export GOPROXY='a'"}))
+	rc = UpsertBlock("work", rc, RenderBlock("work", []string{"export GOPRIVATE='corp.com'"}))
+	rc = UpsertBlock("", rc, RenderBlock("", []string{"export GOPROXY='b'"}))
+
+	blocks := ProfileMarkers.Blocks(rc)
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks, want 2:\n%s", len(blocks), rc)
+	}
+	if blocks[0].Name != "" || !slices.Equal(blocks[0].Lines, []string{"export GOPROXY='b'"}) {
+		t.Fatalf("first block = %+v", blocks[0])
+	}
+	if blocks[1].Name != "work" || !slices.Equal(blocks[1].Lines, []string{"export GOPRIVATE='corp.com'"}) {
+		t.Fatalf("second block = %+v", blocks[1])
+	}
+
+	rc, ok := RemoveBlock("work", rc)
+	if !ok || strings.Contains(rc, "work") || !strings.Contains(rc, "GOPROXY='b'") {
+		t.Fatalf("RemoveBlock(work) left:\n%s", rc)
+	}
+}
+
+func TestValidateBlockName(t *testing.T) {
+	for _, name := range []string{"", "work", "go-1.22_ci"} {
+		if err := ValidateBlockName(name); err != nil {
+			t.Errorf("ValidateBlockName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"a b", "x#y", "ä"} {
+		if err := ValidateBlockName(name); err == nil {
+			t.Errorf("ValidateBlockName(%q) accepted", name)
+		}
+	}
+}
+
+func TestApplyToRC_ReadOnlyCheckedSymlinkTarget(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "store", "bashrc")
+	if err := os.MkdirAll(filepath.Dir(real), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(real, []byte("export PATH=$PATH\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rc := filepath.Join(dir, ".bashrc")
+	if err := os.Symlink(real, rc); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	saved := readOnly
+	readOnly = func(target string) bool { return target == real }
+	t.Cleanup(func() { readOnly = saved })
+
+	lines := []string{"export GOPROXY='x'"}
+	if _, err := ApplyToRC(rc, lines, ApplyOptions{}); err == nil || !strings.Contains(err.Error(), "--replace-symlink") {
+		t.Fatalf("ApplyToRC through a read-only symlink target: err = %v", err)
+	}
+	res, err := ApplyToRC(rc, lines, ApplyOptions{ReplaceSymlink: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(rc); err != nil || !fi.Mode().IsRegular() || res.Target != rc {
+		t.Fatalf("rc was not replaced by a regular file: %v, %v", fi.Mode(), err)
+	}
+	if b, _ := os.ReadFile(real); string(b) != "export PATH=$PATH\n" {
+		t.Fatalf("read-only target changed:\n%s", b)
+	}
+}
+
+func TestApplyToRC_ReadOnlySymlinkTarget(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root can write read-only directories")
+	}
+	dir := t.TempDir()
+	ro := filepath.Join(dir, "store")
+	if err := os.MkdirAll(ro, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	real := filepath.Join(ro, "bashrc")
+	if err := os.WriteFile(real, []byte("export PATH=$PATH\n"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(ro, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chmod(ro, 0o755) })
+	rc := filepath.Join(dir, ".bashrc")
+	if err := os.Symlink(real, rc); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	lines := []string{"export GOPROXY='x'"}
+	if _, err := ApplyToRC(rc, lines, ApplyOptions{}); err == nil {
+		t.Fatal("ApplyToRC wrote through a symlink into a read-only directory")
+	}
+	res, err := ApplyToRC(rc, lines, ApplyOptions{ReplaceSymlink: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(rc); err != nil || !fi.Mode().IsRegular() || res.Target != rc {
+		t.Fatalf("rc was not replaced by a regular file: %v, %v", fi.Mode(), err)
+	}
+	if b, _ := os.ReadFile(real); string(b) != "export PATH=$PATH\n" {
+		t.Fatalf("read-only target changed:\n%s", b)
+	}
+}
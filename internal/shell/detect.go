@@ -0,0 +1,70 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OverrideEnv forces the detected shell, e.g. GPX_SHELL=fish.
+const OverrideEnv = "GPX_SHELL"
+
+// Detection is the result of Detect: which shell and where the answer came from.
+type Detection struct {
+	Shell  string
+	Source string
+}
+
+func (d Detection) String() string {
+	return fmt.Sprintf("%s (from %s)", d.Shell, d.Source)
+}
+
+// Detect guesses the user's shell. Sources, in order:
+// GPX_SHELL, the parent process name (/proc/<ppid>/comm), $SHELL.
+// Falls back to "sh" when nothing recognizable is found.
+func Detect() Detection {
+	if sh := Normalize(os.Getenv(OverrideEnv)); sh != "" {
+		return Detection{Shell: sh, Source: OverrideEnv}
+	}
+	if sh := Normalize(parentComm()); sh != "" {
+		return Detection{Shell: sh, Source: "parent process"}
+	}
+	if sh := Normalize(os.Getenv("SHELL")); sh != "" {
+		return Detection{Shell: sh, Source: "SHELL"}
+	}
+	return Detection{Shell: "sh", Source: "default"}
+}
+
+// Normalize maps a shell path or process name to a supported shell name:
+// sh, bash, zsh, fish, pwsh or nu. It returns "" for anything else.
+func Normalize(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	name = filepath.Base(name)
+	name = strings.TrimPrefix(name, "-") // login shells: -zsh
+	name = strings.TrimSuffix(strings.ToLower(name), ".exe")
+	switch name {
+	case "sh", "dash", "ash":
+		return "sh"
+	case "bash", "zsh", "fish":
+		return name
+	case "pwsh", "powershell":
+		return "pwsh"
+	case "nu", "nushell":
+		return "nu"
+	default:
+		return ""
+	}
+}
+
+// parentComm returns the parent process name on systems with procfs.
+func parentComm() string {
+	b, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", os.Getppid()))
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
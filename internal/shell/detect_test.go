@@ -0,0 +1,34 @@
+package shell
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"/bin/zsh", "zsh"},
+		{"-bash", "bash"},
+		{"fish\n", "fish"},
+		{"/usr/bin/dash", "sh"},
+		{"pwsh.exe", "pwsh"},
+		{"nu", "nu"},
+		{"make", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Fatalf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDetect_Override(t *testing.T) {
+	t.Setenv(OverrideEnv, "fish")
+	t.Setenv("SHELL", "/bin/bash")
+	got := Detect()
+	if got.Shell != "fish" || got.Source != OverrideEnv {
+		t.Fatalf("Detect() = %+v, want fish from %s", got, OverrideEnv)
+	}
+}
@@ -0,0 +1,99 @@
+package shell
+
+import (
+	"fmt"
+)
+
+const posixInit = `gpx() {
+  case "$1" in
+    use|set|unset|off)
+      local __gpx_out __gpx_rc
+      __gpx_out="$(GPX_SHELL=%[1]s command gpx "$@")"
+      __gpx_rc=$?
+      if [ $__gpx_rc -ne 0 ]; then
+        return $__gpx_rc
+      fi
+      eval "$__gpx_out"
+      ;;
+    *)
+      command gpx "$@"
+      ;;
+  esac
+}
+`
+
+const fishInit = `function gpx
+    switch "$argv[1]"
+        case use set unset off
+            set -l __gpx_out (GPX_SHELL=fish command gpx $argv)
+            or return $status
+            printf '%s\n' $__gpx_out | source
+        case '*'
+            command gpx $argv
+    end
+end
+`
+
+// Hooks run `gpx hook` when the working directory changes
+// (see directory-bound profiles) and once at startup.
+const zshHook = `_gpx_hook() {
+  eval "$(GPX_SHELL=zsh command gpx hook)"
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd _gpx_hook
+_gpx_hook
+`
+
+const bashHook = `_gpx_hook() {
+  local __gpx_status=$?
+  eval "$(GPX_SHELL=bash command gpx hook)"
+  return $__gpx_status
+}
+if [[ ";${PROMPT_COMMAND[*]:-};" != *";_gpx_hook;"* ]]; then
+  PROMPT_COMMAND="_gpx_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`
+
+const fishHook = `function __gpx_hook --on-variable PWD
+    GPX_SHELL=fish command gpx hook | source
+end
+__gpx_hook
+`
+
+// InitScript returns the shell function that wraps the gpx binary so that
+// `gpx use`, `set`, `unset` and `off` apply to the current shell without eval.
+// All other subcommands are passed through unchanged.
+// With hook, the script also applies directory-bound profiles on cd.
+func InitScript(shell string, hook bool) (string, error) {
+	var script, h string
+	switch shell {
+	case "bash":
+		script, h = fmt.Sprintf(posixInit, shell), bashHook
+	case "zsh":
+		script, h = fmt.Sprintf(posixInit, shell), zshHook
+	case "fish":
+		script, h = fishInit, fishHook
+	default:
+		return "", fmt.Errorf("shell-init: unsupported shell %q (expected bash, zsh or fish)", shell)
+	}
+	if hook {
+		script += h
+	}
+	return script, nil
+}
+
+// InitLines returns the rc file lines that load InitScript on shell startup.
+func InitLines(shell string, hook bool) ([]string, error) {
+	args := shell
+	if hook {
+		args = "--hook " + shell
+	}
+	switch shell {
+	case "bash", "zsh":
+		return []string{fmt.Sprintf(`eval "$(command gpx shell-init %s)"`, args)}, nil
+	case "fish":
+		return []string{fmt.Sprintf("command gpx shell-init %s | source", args)}, nil
+	default:
+		return nil, fmt.Errorf("shell-init: unsupported shell %q (expected bash, zsh or fish)", shell)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 )
 
 func DefaultRC(shell string) (string, error) {
@@ -12,11 +14,42 @@ func DefaultRC(shell string) (string, error) {
 		return "", fmt.Errorf("get home dir: %w", err)
 	}
 	switch shell {
+	case "sh":
+		return filepath.Join(home, ".profile"), nil
 	case "zsh":
 		return filepath.Join(home, ".zshrc"), nil
 	case "bash":
 		return filepath.Join(home, ".bashrc"), nil
+	case "fish":
+		return filepath.Join(home, ".config", "fish", "conf.d", "gpx.fish"), nil
+	case "pwsh", "powershell":
+		// $PROFILE (CurrentUserCurrentHost)
+		if runtime.GOOS == "windows" {
+			return filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1"), nil
+		}
+		return filepath.Join(home, ".config", "powershell", "Microsoft.PowerShell_profile.ps1"), nil
+	case "nu", "nushell":
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("get config dir: %w", err)
+		}
+		return filepath.Join(dir, "nushell", "env.nu"), nil
 	default:
-		return "", fmt.Errorf("unsupported shell %q (expected zsh or bash)", shell)
+		return "", fmt.Errorf("unsupported shell %q (expected sh, zsh, bash, fish, pwsh or nu)", shell)
+	}
+}
+
+// ShellForRC guesses the shell from an rc file extension.
+// It returns "" for files without a recognizable extension.
+func ShellForRC(rcPath string) string {
+	switch strings.ToLower(filepath.Ext(rcPath)) {
+	case ".fish":
+		return "fish"
+	case ".ps1":
+		return "pwsh"
+	case ".nu":
+		return "nu"
+	default:
+		return ""
 	}
 }
@@ -1,17 +1,48 @@
 package state
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/ZeraiGR/gpx/internal/fsx"
 )
 
 type State struct {
 	ActiveProfile string `json:"active_profile"`
+	// Allowed maps trusted .gpx file paths to the sha256 of their allowed content.
+	Allowed map[string]string `json:"allowed,omitempty"`
+	// Blocks maps rc file paths to the profiles applied in their named GPX
+	// blocks, by block name. The unnamed block sets ActiveProfile instead.
+	Blocks map[string]map[string]string `json:"blocks,omitempty"`
 }
 
-func defaultPath() (string, error) {
+// PathEnv overrides the state file location.
+const PathEnv = "GPX_STATE"
+
+// DefaultPath returns $GPX_STATE if set, otherwise
+// $XDG_STATE_HOME/gpx/state.json (~/.local/state/gpx/state.json when
+// XDG_STATE_HOME is unset).
+func DefaultPath() (string, error) {
+	if p := os.Getenv(PathEnv); p != "" {
+		return p, nil
+	}
+	if xdg := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "gpx", "state.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("get home dir: %w", err)
+	}
+	return filepath.Join(home, ".local", "state", "gpx", "state.json"), nil
+}
+
+// legacyPath is where state lived before it moved to XDG_STATE_HOME.
+func legacyPath() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", fmt.Errorf("get home dir: %w", err)
@@ -19,10 +50,58 @@ func defaultPath() (string, error) {
 	return filepath.Join(home, ".config", "gpx", "state.json"), nil
 }
 
-func Load() (*State, error) {
-	path, err := defaultPath()
+// migrateLegacy moves the legacy state file to path if only the former
+// exists. A failed move is not fatal: the legacy file is read instead.
+func migrateLegacy(path string) string {
+	if os.Getenv(PathEnv) != "" {
+		return path
+	}
+	legacy, err := legacyPath()
+	if err != nil || legacy == path {
+		return path
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return path
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		return path
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return legacy
+	}
+	if err := os.Rename(legacy, path); err != nil {
+		return legacy
+	}
+	return path
+}
+
+// ForConfig returns the state file kept for a config file other than the
+// default one: the active profile of work.json is not the one of the
+// default config. It lives next to DefaultPath under configs/, named after
+// the config and a hash of its absolute path.
+func ForConfig(configPath string) (string, error) {
+	def, err := DefaultPath()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(configPath)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("abs %s: %w", configPath, err)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	name := strings.TrimSuffix(filepath.Base(abs), filepath.Ext(abs))
+	return filepath.Join(filepath.Dir(def), "configs", name+"-"+hex.EncodeToString(sum[:4])+".json"), nil
+}
+
+// Store is a state file.
+type Store struct {
+	Path string
+}
+
+func (st Store) Load() (*State, error) {
+	path := st.Path
+	if def, err := DefaultPath(); err == nil && def == path {
+		path = migrateLegacy(path)
 	}
 	b, err := os.ReadFile(path)
 	if err != nil {
@@ -38,14 +117,11 @@ func Load() (*State, error) {
 	return &s, nil
 }
 
-func Save(s *State) error {
+func (st Store) Save(s *State) error {
 	if s == nil {
 		return fmt.Errorf("state is nil")
 	}
-	path, err := defaultPath()
-	if err != nil {
-		return err
-	}
+	path := st.Path
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("mkdir %s: %w", dir, err)
@@ -55,21 +131,100 @@ func Save(s *State) error {
 		return fmt.Errorf("marshal state: %w", err)
 	}
 
-	tmp := path + ".tmp"
-	if err := os.WriteFile(tmp, b, 0o644); err != nil {
-		return fmt.Errorf("write temp state %s: %w", tmp, err)
-	}
-	if err := os.Rename(tmp, path); err != nil {
+	if err := fsx.WriteFile(path, b, 0o644); err != nil {
 		return fmt.Errorf("replace state %s: %w", path, err)
 	}
 	return nil
 }
 
-func SetActiveProfile(name string) error {
-	s, err := Load()
+func (st Store) SetActiveProfile(name string) error {
+	s, err := st.Load()
 	if err != nil {
 		return err
 	}
 	s.ActiveProfile = name
-	return Save(s)
+	return st.Save(s)
+}
+
+// ClearActiveProfile forgets the active profile if it is name, or
+// whatever it is when name is "".
+func (st Store) ClearActiveProfile(name string) error {
+	s, err := st.Load()
+	if err != nil {
+		return err
+	}
+	if s.ActiveProfile == "" || (name != "" && s.ActiveProfile != name) {
+		return nil
+	}
+	s.ActiveProfile = ""
+	return st.Save(s)
+}
+
+// SetBlockProfile records the profile applied in the named block of an rc file.
+func (st Store) SetBlockProfile(rcPath, block, profile string) error {
+	s, err := st.Load()
+	if err != nil {
+		return err
+	}
+	if s.Blocks == nil {
+		s.Blocks = map[string]map[string]string{}
+	}
+	if s.Blocks[rcPath] == nil {
+		s.Blocks[rcPath] = map[string]string{}
+	}
+	s.Blocks[rcPath][block] = profile
+	return st.Save(s)
+}
+
+// ClearBlockProfile forgets the profile of the named block of an rc file.
+func (st Store) ClearBlockProfile(rcPath, block string) error {
+	s, err := st.Load()
+	if err != nil {
+		return err
+	}
+	if _, ok := s.Blocks[rcPath][block]; !ok {
+		return nil
+	}
+	delete(s.Blocks[rcPath], block)
+	if len(s.Blocks[rcPath]) == 0 {
+		delete(s.Blocks, rcPath)
+	}
+	return st.Save(s)
+}
+
+// BlockProfile returns the profile recorded for the named block of an rc
+// file, or "".
+func (s *State) BlockProfile(rcPath, block string) string {
+	if s == nil {
+		return ""
+	}
+	return s.Blocks[rcPath][block]
+}
+
+// Allow trusts the given version (sum) of a .gpx file.
+func (st Store) Allow(path, sum string) error {
+	s, err := st.Load()
+	if err != nil {
+		return err
+	}
+	if s.Allowed == nil {
+		s.Allowed = map[string]string{}
+	}
+	s.Allowed[path] = sum
+	return st.Save(s)
+}
+
+// Deny removes a .gpx file from the trusted list.
+func (st Store) Deny(path string) error {
+	s, err := st.Load()
+	if err != nil {
+		return err
+	}
+	delete(s.Allowed, path)
+	return st.Save(s)
+}
+
+// IsAllowed reports whether this exact version of a .gpx file is trusted.
+func (s *State) IsAllowed(path, sum string) bool {
+	return s != nil && s.Allowed[path] == sum
 }
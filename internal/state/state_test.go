@@ -0,0 +1,84 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore_RoundTrip(t *testing.T) {
+	st := Store{Path: filepath.Join(t.TempDir(), "nested", "state.json")}
+	if s, err := st.Load(); err != nil || s.ActiveProfile != "" {
+		t.Fatalf("Load of a missing file = %+v, %v", s, err)
+	}
+	if err := st.SetActiveProfile("corp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Allow("/repo/.gpx", "sum1"); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := st.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ActiveProfile != "corp" || !s.IsAllowed("/repo/.gpx", "sum1") || s.IsAllowed("/repo/.gpx", "sum2") {
+		t.Fatalf("state = %+v", s)
+	}
+	if err := st.Deny("/repo/.gpx"); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := st.Load(); s.IsAllowed("/repo/.gpx", "sum1") {
+		t.Fatal("Deny kept the file trusted")
+	}
+}
+
+func TestForConfig(t *testing.T) {
+	t.Setenv(PathEnv, "")
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	a, err := ForConfig("/home/me/work.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ForConfig("/srv/work.json")
+	if a == b {
+		t.Fatalf("configs with the same name share %s", a)
+	}
+	if again, _ := ForConfig("/home/me/work.json"); again != a {
+		t.Fatalf("ForConfig is not stable: %s, %s", a, again)
+	}
+	if filepath.Base(filepath.Dir(a)) != "configs" {
+		t.Fatalf("ForConfig = %s, want it under configs/", a)
+	}
+}
+
+func TestLoad_MovesLegacyFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(PathEnv, "")
+	t.Setenv("XDG_STATE_HOME", "")
+
+	legacy := filepath.Join(home, ".config", "gpx", "state.json")
+	if err := os.MkdirAll(filepath.Dir(legacy), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacy, []byte(`{"active_profile": "public"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := DefaultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Store{Path: path}.Load()
+	if err != nil || s.ActiveProfile != "public" {
+		t.Fatalf("Load = %+v, %v", s, err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Fatalf("legacy file still exists: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("state was not moved to %s: %v", path, err)
+	}
+}